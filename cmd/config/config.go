@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	secretifyconfig "secretify-cli/internal/config"
+	"secretify-cli/internal/creds"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the configuration",
+		Long: `Manage the configuration.
+
+Settings are read from the user file ~/.config/secretify/config.yaml and the
+project file .secretify.yaml in the working directory or its nearest parent.
+Top-level settings apply to all profiles, settings below profiles.<name> to
+the named profile only:
+
+  expires_at: 7d
+  profiles:
+    work:
+      url: https://secretify.example.com
+      views: 3
+
+Flags take precedence over environment variables such as SECRETIFY_VIEWS,
+environment variables over the project file, the project file over the user
+file and the user file over the defaults.
+
+Settings marked with * affect where requests are sent or how they are
+secured. As project files may come from untrusted checkouts, these settings
+are rejected in project files.
+
+Settings:
+` + usage(),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// The configuration is not applied to flags, so that it can be
+		// repaired if invalid
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+	cmd.PersistentFlags().Bool("project", false, "Use the project file instead of the user file")
+
+	cmd.AddCommand(newGet(), newSet(), newList(), newEdit())
+	return cmd
+}
+
+func newGet() *cobra.Command {
+	return &cobra.Command{
+		Use:           "get KEY",
+		Short:         "Print the value of a setting",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := load(cmd)
+			if err != nil {
+				return err
+			}
+			value, _, err := cfg.Lookup(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+func newSet() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Set a setting",
+		Long: `Set a setting in the user file, or in the project file with --project.
+
+With --profile the setting applies to the profile only. An empty value
+removes the setting.`,
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := filePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Only write below profiles if the profile is set explicitly
+			profile := ""
+			if cmd.Flags().Changed("profile") {
+				profile, err = cmd.Flags().GetString("profile")
+				if err != nil {
+					return err
+				}
+				if err := creds.ValidateProfile(profile); err != nil {
+					return err
+				}
+			}
+
+			if err := secretifyconfig.Set(path, profile, args[0], args[1]); err != nil {
+				return err
+			}
+
+			// Validate the whole file after the change
+			if _, err := secretifyconfig.ReadFile(path); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+func newList() *cobra.Command {
+	return &cobra.Command{
+		Use:           "list",
+		Short:         "List all settings with their value and source",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := load(cmd)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			for _, name := range secretifyconfig.Names() {
+				value, source, err := cfg.Lookup(name)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, value, source)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newEdit() *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "Open the configuration file in an editor",
+		Long: `Open the user file, or the project file with --project, in $VISUAL or
+$EDITOR. The file is validated after the editor exits.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := filePath(cmd)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+
+			// Open the file in the editor of the user
+			editor := os.Getenv("VISUAL")
+			if editor == "" {
+				editor = os.Getenv("EDITOR")
+			}
+			if editor == "" {
+				editor = "vi"
+				if runtime.GOOS == "windows" {
+					editor = "notepad"
+				}
+			}
+			fields := strings.Fields(editor)
+			c := exec.Command(fields[0], append(fields[1:], path)...)
+			c.Stdin = os.Stdin
+			c.Stdout = os.Stdout
+			c.Stderr = os.Stderr
+			if err := c.Run(); err != nil {
+				return fmt.Errorf("editor: %v", err)
+			}
+
+			// Validate the edited file
+			if _, err := secretifyconfig.ReadFile(path); err != nil {
+				return fmt.Errorf("invalid configuration: %v", err)
+			}
+			return nil
+		},
+	}
+}
+
+// load loads the configuration of the profile.
+func load(cmd *cobra.Command) (*secretifyconfig.Config, error) {
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+	return secretifyconfig.Load(creds.ResolveProfile(profile))
+}
+
+// filePath returns the path of the user file, or of the project file if
+// --project is set. Without a project file, it is created in the working
+// directory.
+func filePath(cmd *cobra.Command) (string, error) {
+	project, err := cmd.Flags().GetBool("project")
+	if err != nil {
+		return "", err
+	}
+	if !project {
+		return secretifyconfig.UserFilePath()
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if path := secretifyconfig.FindProjectFile(wd); path != "" {
+		return path, nil
+	}
+	return filepath.Join(wd, secretifyconfig.ProjectFileName), nil
+}
+
+// usage describes all settings.
+func usage() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, k := range secretifyconfig.Keys {
+		flag := ""
+		if k.Flag != "" {
+			flag = "--" + k.Flag
+		}
+		name := k.Name
+		if k.UserOnly {
+			name += "*"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", name, flag, k.Usage)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// RegisterCommandsRecursive registers the config command and its subcommands.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newConfig())
+}
@@ -1,22 +1,53 @@
 package create
 
 import (
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"secretify-cli/internal"
 	"secretify-cli/internal/creds"
+	"secretify-cli/internal/expiry"
+	"secretify-cli/internal/prompt"
 	"secretify-cli/internal/util"
 	secretifyclient "secretify-cli/pkg/client"
 	"secretify-cli/pkg/crypto"
+	"secretify-cli/pkg/generate"
+	secretlink "secretify-cli/pkg/link"
+	"secretify-cli/pkg/shamir"
 
 	"github.com/spf13/cobra"
 )
 
 func newCreate() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:           "create [SECRET_TYPE]",
-		Short:         "Create a new secret link",
+		Use:   "create [SECRET_TYPE]",
+		Short: "Create a new secret link",
+		Long: `Create a new secret link.
+
+If no data is provided with --set and a terminal is attached, the fields of
+the secret type are prompted for interactively. Use --no-input to disable
+prompting, e.g. in scripts.
+
+Values are encrypted as by the web app, so the link can be opened in the
+browser. With --manifest the whole secret is authenticated, so that reveal
+detects dropped or exchanged fields. Such secrets, like those created with
+--kdf or recipients, can only be revealed by clients supporting manifests.
+
+With --split K-of-N the key is not appended to the link but split into N
+shares, of which any K are required to reveal the secret. The link is
+printed on the first line followed by one share per line.
+
+With --recipient or --recipient-file the key is wrapped to the X25519 public
+keys of the recipients and stored with the secret. The link contains no key
+and the secret can only be revealed with one of the matching identities,
+see keygen.
+
+Values encrypted offline with seal can be added with --sealed FIELD=FILE
+together with the key printed by seal, which then becomes the key of the
+link.`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -31,8 +62,66 @@ func newCreate() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("error retrieving data: %v", err)
 			}
-			dataMap := util.ExtractDataSets(dataSets)
-			if len(dataMap) == 0 {
+			dataMap := make(map[string][]byte)
+			for field, value := range util.ExtractDataSets(dataSets) {
+				dataMap[field] = []byte(value)
+			}
+			defer func() { crypto.WipeDataMap(dataMap) }()
+
+			// Generate values for fields requested with --generate
+			generates, err := cmd.Flags().GetStringArray("generate")
+			if err != nil {
+				return fmt.Errorf("error retrieving generate: %v", err)
+			}
+			for _, g := range generates {
+				field, spec, err := generate.ParseFieldSpec(g)
+				if err != nil {
+					return fmt.Errorf("error generate: %v", err)
+				}
+				if _, ok := dataMap[field]; ok {
+					return fmt.Errorf("field %s is both set and generated", field)
+				}
+				value, err := generate.Generate(spec)
+				if err != nil {
+					return fmt.Errorf("error generate: %v", err)
+				}
+				dataMap[field] = []byte(value)
+			}
+
+			// Read values sealed offline with the key provided with --key
+			sealedSets, err := cmd.Flags().GetStringArray("sealed")
+			if err != nil {
+				return fmt.Errorf("error retrieving sealed: %v", err)
+			}
+			keyString, err := cmd.Flags().GetString("key")
+			if err != nil {
+				return fmt.Errorf("error key: %v", err)
+			}
+			var providedKey []byte
+			if keyString != "" {
+				providedKey, err = secretlink.DecodeKey(keyString)
+				if err != nil {
+					return err
+				}
+				crypto.Lock(providedKey)
+				defer crypto.Destroy(providedKey)
+			}
+			sealedMap, err := readSealed(util.ExtractDataSets(sealedSets), providedKey)
+			if err != nil {
+				return err
+			}
+			for field := range sealedMap {
+				if _, ok := dataMap[field]; ok {
+					return fmt.Errorf("field %s is both set and sealed", field)
+				}
+			}
+
+			noInput, err := cmd.Flags().GetBool("no-input")
+			if err != nil {
+				return err
+			}
+			interactive := len(dataMap) == 0 && len(sealedMap) == 0 && !noInput && prompt.IsInteractive()
+			if len(dataMap) == 0 && len(sealedMap) == 0 && !interactive {
 				return fmt.Errorf("no data provided. Use e.g. --set message=your_secret")
 			}
 
@@ -45,52 +134,389 @@ func newCreate() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("error views: %v", err)
 			}
+			destroyable, err := cmd.Flags().GetBool("destroyable")
+			if err != nil {
+				return err
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %s, supported are text and json", output)
+			}
+
+			// Retrieve the padding scheme to hide the length of the values
+			paddingName, err := cmd.Flags().GetString("padding")
+			if err != nil {
+				return fmt.Errorf("error padding: %v", err)
+			}
+			padding, err := crypto.ParsePadding(paddingName)
+			if err != nil {
+				return err
+			}
+
+			// Retrieve the cipher suite
+			cipherName, err := cmd.Flags().GetString("cipher")
+			if err != nil {
+				return fmt.Errorf("error cipher: %v", err)
+			}
+			cipher, err := crypto.ParseCipher(cipherName)
+			if err != nil {
+				return err
+			}
+
+			// Retrieve the key derivation
+			kdfName, err := cmd.Flags().GetString("kdf")
+			if err != nil {
+				return fmt.Errorf("error kdf: %v", err)
+			}
+			kdf, err := crypto.ParseKDF(kdfName)
+			if err != nil {
+				return err
+			}
+
+			// Authenticate the whole secret with a manifest if requested, which
+			// derived keys and keys wrapped to recipients require
+			manifest, err := cmd.Flags().GetBool("manifest")
+			if err != nil {
+				return err
+			}
+
+			// Retrieve the compression algorithm for large values
+			compressionName, err := cmd.Flags().GetString("compression")
+			if err != nil {
+				return fmt.Errorf("error compression: %v", err)
+			}
+			compression, err := crypto.ParseCompression(compressionName)
+			if err != nil {
+				return err
+			}
+
+			// Retrieve the key split scheme if the key should be split into shares
+			split, err := cmd.Flags().GetString("split")
+			if err != nil {
+				return fmt.Errorf("error split: %v", err)
+			}
+			var threshold, shareCount int
+			if split != "" {
+				threshold, shareCount, err = shamir.ParseScheme(split)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Parse the public keys of the recipients the key should be wrapped to
+			recipientKeys, err := cmd.Flags().GetStringArray("recipient")
+			if err != nil {
+				return fmt.Errorf("error recipient: %v", err)
+			}
+			recipientFiles, err := cmd.Flags().GetStringArray("recipient-file")
+			if err != nil {
+				return fmt.Errorf("error recipient: %v", err)
+			}
+			recipients, err := crypto.ParseRecipients(recipientKeys, recipientFiles)
+			if err != nil {
+				return err
+			}
+			if len(recipients) > 0 && split != "" {
+				return fmt.Errorf("--split cannot be combined with recipients")
+			}
+			manifest = manifest || kdf != crypto.KDFNone || len(recipients) > 0
 
-			// Authenticate and get token, create newly authenticated client
-			url, username, password, err := creds.GetCredentials()
+			// Links point to the instance unless another base URL is configured,
+			// e.g. for instances reached over a Unix domain socket
+			linkURL, err := cmd.Flags().GetString("link-url")
+			if err != nil {
+				return err
+			}
+
+			// Create a client authenticating with the stored credentials, tokens
+			// are renewed when expired
+			profile, err := cmd.Flags().GetString("profile")
+			if err != nil {
+				return err
+			}
+			credentials, err := creds.GetCredentials(profile)
 			if err != nil {
 				return fmt.Errorf("authentication: %v", err)
 			}
-			token, err := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), "").Login(username, password)
+			url := credentials.URL
+			if linkURL == "" {
+				linkURL = url
+			}
+			if strings.HasPrefix(linkURL, secretifyclient.UnixScheme+":") {
+				return fmt.Errorf("instance %s is reached over a Unix domain socket, use --link-url to set the base URL of links", url)
+			}
+			aClient := secretifyclient.NewHTTPWithTokenSource(fmt.Sprintf(internal.APIURL, url), creds.TokenSource(credentials))
+			aClient.Client, err = creds.HTTPClient(credentials)
 			if err != nil {
-				return fmt.Errorf("could not authenticate: %v", err)
+				return err
 			}
-			aClient := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), token)
 
 			// Check if the provided secret type exists
-			typeID, err := aClient.CheckType(dataType)
+			secretType, err := aClient.GetType(dataType)
 			if err != nil {
 				return fmt.Errorf("error type: %v", err)
 			}
 
-			// Generate encryption key and decrypt data
-			key, err := crypto.GenerateEncryptionKeyString()
+			// Validate expiry and views against the limits of the server
+			serverLimits, err := aClient.GetLimits()
 			if err != nil {
-				return fmt.Errorf("error key: %v", err)
+				return fmt.Errorf("error limits: %v", err)
+			}
+			limits, err := toExpiryLimits(serverLimits)
+			if err != nil {
+				return fmt.Errorf("error limits: %v", err)
+			}
+			expiresTime, expiresIn, err := expiry.Resolve(expiresAt, views, limits, time.Now())
+			if err != nil {
+				return err
+			}
+
+			// Prompt for the fields of the secret type if no data was provided
+			if interactive {
+				dataMap, err = promptFields(secretType)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Preview expiry and views before submitting
+			fmt.Fprintf(os.Stderr, "Expires:\t%s (in %s)\nViews:\t\t%d\n", expiresTime.Format(time.RFC1123), expiry.Format(expiresIn), views)
+			if interactive {
+				ok, err := prompt.Confirm("Create secret link?", true)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("aborted")
+				}
+			}
+
+			// Field names starting with an underscore are reserved
+			for k := range sealedMap {
+				if crypto.IsReservedField(k) {
+					return fmt.Errorf("field name %s is reserved", k)
+				}
+			}
+			for k := range dataMap {
+				if crypto.IsReservedField(k) {
+					return fmt.Errorf("field name %s is reserved", k)
+				}
 			}
-			encryptedDataMap, err := crypto.EncryptDataMap(dataMap, key)
+
+			// Generate encryption key unless provided and encrypt data
+			key := providedKey
+			if key == nil {
+				key, err = crypto.GenerateEncryptionKeyString()
+				if err != nil {
+					return fmt.Errorf("error key: %v", err)
+				}
+				defer crypto.Destroy(key)
+			}
+			encryptedDataMap, err := crypto.SealDataMap(dataMap, key, crypto.Options{Cipher: cipher, KDF: kdf, Compression: compression, Padding: padding, Type: secretType.Identifier, Manifest: manifest})
 			if err != nil {
 				return fmt.Errorf("error encryption: %v", err)
 			}
+			// Seal the sealed values again with the key of their field if keys
+			// are derived and marked as part of the manifest
+			for field, sealed := range sealedMap {
+				resealed, err := crypto.ResealField(sealed, key, field, crypto.Options{KDF: kdf, Manifest: manifest})
+				if err != nil {
+					return fmt.Errorf("error sealed value %s: %v", field, err)
+				}
+				encryptedDataMap[field] = resealed
+			}
+
+			// Wrap the key to the recipients instead of putting it into the link
+			if len(recipients) > 0 {
+				wrappedKey, err := crypto.WrapKey(key, recipients)
+				if err != nil {
+					return fmt.Errorf("error recipient: %v", err)
+				}
+				encryptedDataMap[crypto.WrappedKeyField] = wrappedKey
+			}
+
+			// Authenticate the sealed values and the wrapped key as well
+			if manifest && (len(sealedMap) > 0 || len(recipients) > 0) {
+				if err := crypto.AddManifest(encryptedDataMap, secretType.Identifier, key); err != nil {
+					return fmt.Errorf("error manifest: %v", err)
+				}
+			}
+
+			// Resolve the expiry again so that absolute expiries do not shift
+			// by the time spent prompting
+			_, expiresIn, err = expiry.Resolve(expiresAt, views, limits, time.Now())
+			if err != nil {
+				return err
+			}
 
 			// Create a secret link
-			crateRes, err := aClient.Create(typeID, encryptedDataMap, expiresAt, views, false, false, false)
+			crateRes, err := aClient.Create(secretType.ID, encryptedDataMap, expiry.Format(expiresIn), views, destroyable, false, false)
 			if err != nil {
 				return fmt.Errorf("error client: %v", err)
 			}
+			res := result{Identifier: crateRes.Identifier}
+
+			switch {
+			case len(recipients) > 0:
+				// Print the link without key if only the recipients can unwrap it
+				res.Link = secretlink.Format(linkURL, crateRes.Identifier, nil)
+			case split != "":
+				// Print the link and the key shares instead of the key if split
+				shares, err := shamir.SplitShares(key, threshold, shareCount)
+				if err != nil {
+					return fmt.Errorf("error split: %v", err)
+				}
+				res.Link = secretlink.Format(linkURL, crateRes.Identifier, nil)
+				for _, share := range shares {
+					res.Shares = append(res.Shares, share.String())
+				}
+			default:
+				// Print the generated secret link
+				res.Link = secretlink.Format(linkURL, crateRes.Identifier, key)
+			}
 
-			// Print the generated secret link
-			fmt.Printf("%s/s/%s#%s\n", url, crateRes.Identifier, base64.RawURLEncoding.EncodeToString(key))
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(res)
+			}
+			fmt.Println(res.Link)
+			for _, share := range res.Shares {
+				fmt.Println(share)
+			}
 
 			return nil
 		},
 	}
 	cmd.Flags().StringArray("set", nil, "Your secret sets")
-	cmd.Flags().String("expiresAt", "24h", "Expiration duration")
+	cmd.Flags().String("expiresAt", "24h", "Expiration as duration (30m, 7d), RFC3339 timestamp or e.g. \"tomorrow 09:00\"")
 	cmd.Flags().Int("views", 1, "Number of views")
+	cmd.Flags().Bool("destroyable", false, "Allow recipients to destroy the secret")
+	cmd.Flags().String("output", "text", "Output format: text or json")
+	cmd.Flags().String("link-url", "", "Base URL of the printed link, defaults to the URL of the instance")
+	cmd.Flags().StringArray("generate", nil, "Generate a secure value for a field, e.g. password:length=32,symbols")
+	cmd.Flags().String("cipher", string(crypto.CipherAESGCM), "Cipher to encrypt values with: aes-256-gcm or xchacha20-poly1305")
+	cmd.Flags().String("kdf", "none", "Derive per-field keys from the link key: none or hkdf, implies --manifest")
+	cmd.Flags().Bool("manifest", false, "Authenticate the whole secret with a manifest, which the web app cannot reveal yet")
+	cmd.Flags().String("padding", "none", "Pad values to hide their length: none, padme or pow2")
+	cmd.Flags().String("compression", "none", "Compress values larger than 1 KiB before encryption: none or deflate")
+	cmd.Flags().String("split", "", "Split the key into shares of which a threshold is required to reveal, e.g. 3-of-5")
+	cmd.Flags().StringArray("recipient", nil, "Encrypt the key to a public key (age1...) instead of the link")
+	cmd.Flags().StringArray("recipient-file", nil, "Encrypt the key to the public keys in the file, one per line")
+	cmd.Flags().StringArray("sealed", nil, "Add a value sealed offline from a file, e.g. key=id_rsa.sealed")
+	cmd.Flags().String("key", "", "Key printed by seal, used instead of a new key")
+	cmd.Flags().Bool("no-input", false, "Never prompt for missing data")
 	return cmd
 }
 
+// result is the output of create.
+type result struct {
+	Link       string   `json:"link"`
+	Identifier string   `json:"identifier"`
+	Shares     []string `json:"shares,omitempty"`
+}
+
+// promptFields asks for the value of each field of the given secret type.
+// The values should be wiped with crypto.WipeDataMap after use.
+func promptFields(t *secretifyclient.Type) (map[string][]byte, error) {
+	if len(t.Fields) == 0 {
+		return nil, fmt.Errorf("type %s has no fields to prompt for. Use e.g. --set message=your_secret", t.Identifier)
+	}
+
+	dataMap := make(map[string][]byte, len(t.Fields))
+	for _, f := range t.Fields {
+		var value []byte
+		var err error
+		switch {
+		case f.IsPassword():
+			value, err = prompt.ConfirmedSecret(f.Label())
+		case f.IsSensitive():
+			value, err = prompt.Secret(f.Label())
+		case f.IsMultiline():
+			var s string
+			s, err = prompt.Multiline(f.Label())
+			value = []byte(s)
+		default:
+			var s string
+			s, err = prompt.Line(f.Label())
+			value = []byte(s)
+		}
+		if err != nil {
+			crypto.WipeDataMap(dataMap)
+			return nil, fmt.Errorf("error reading %s: %v", f.Label(), err)
+		}
+		if len(value) == 0 {
+			if f.Required {
+				crypto.WipeDataMap(dataMap)
+				return nil, fmt.Errorf("%s is required", f.Label())
+			}
+			continue
+		}
+		dataMap[f.Identifier] = value
+	}
+
+	if len(dataMap) == 0 {
+		return nil, fmt.Errorf("no data provided")
+	}
+	return dataMap, nil
+}
+
+// readSealed reads the blobs created with seal from the files of the given
+// fields and verifies they can be decrypted with the key.
+func readSealed(files map[string]string, key []byte) (map[string]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if key == nil {
+		return nil, fmt.Errorf("sealed values require the key printed by seal. Use --key")
+	}
+
+	sealedMap := make(map[string]string, len(files))
+	for field, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read sealed value %s: %v", field, err)
+		}
+		sealed := strings.TrimSpace(string(b))
+
+		plaintext, err := crypto.Open(sealed, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt sealed value %s with the provided key: %v", field, err)
+		}
+		crypto.Wipe(plaintext)
+		sealedMap[field] = sealed
+	}
+	return sealedMap, nil
+}
+
+// toExpiryLimits converts the limits advertised by the server.
+func toExpiryLimits(l *secretifyclient.Limits) (expiry.Limits, error) {
+	var limits expiry.Limits
+	if l == nil {
+		return limits, nil
+	}
+	if l.MinExpiresAt != "" {
+		d, err := expiry.ParseDuration(l.MinExpiresAt)
+		if err != nil {
+			return limits, fmt.Errorf("invalid minimum expiry %q", l.MinExpiresAt)
+		}
+		limits.MinExpiry = d
+	}
+	if l.MaxExpiresAt != "" {
+		d, err := expiry.ParseDuration(l.MaxExpiresAt)
+		if err != nil {
+			return limits, fmt.Errorf("invalid maximum expiry %q", l.MaxExpiresAt)
+		}
+		limits.MaxExpiry = d
+	}
+	limits.MinViews = l.MinViews
+	limits.MaxViews = l.MaxViews
+	return limits, nil
+}
+
 // RegisterCommandsRecursive registers the create command.
 func RegisterCommandsRecursive(parent *cobra.Command) {
 	parent.AddCommand(newCreate())
@@ -0,0 +1,50 @@
+package create
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"secretify-cli/pkg/crypto"
+)
+
+func TestReadSealed(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	blob, err := crypto.Seal([]byte("v3ryS3ecure$"), key, crypto.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "message.sealed")
+	if err := os.WriteFile(path, []byte(blob+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := readSealed(map[string]string{"message": path}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed["message"] != blob {
+		t.Errorf("read %q, want %q", sealed["message"], blob)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		key   []byte
+		want  string
+	}{
+		{"no key", map[string]string{"message": path}, nil, "require the key"},
+		{"wrong key", map[string]string{"message": path}, bytes.Repeat([]byte{2}, 32), "could not decrypt"},
+		{"missing file", map[string]string{"message": path + ".missing"}, key, "could not read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readSealed(tt.files, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package generate
+
+import (
+	"fmt"
+
+	"secretify-cli/pkg/generate"
+
+	"github.com/spf13/cobra"
+)
+
+func newGenerate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate [SPEC]",
+		Short: "Generate a secure password or token",
+		Long: `Generate a secure password or token using a cryptographically secure
+random source.
+
+SPEC is a comma separated list of options:
+  length=N      password of N characters (default 24)
+  symbols       include symbols in the password
+  hex=N         hexadecimal string of N characters
+  base64=N      N random bytes encoded as URL-safe base64
+  words=N       passphrase of N words from the EFF large wordlist
+  separator=S   separator between the words of a passphrase (default "-")`,
+		Example: `  secretify generate length=32,symbols
+  secretify generate hex=40
+  secretify generate words=5,separator=.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Parse the spec if provided
+			var specString string
+			if len(args) > 0 {
+				specString = args[0]
+			}
+			spec, err := generate.ParseSpec(specString)
+			if err != nil {
+				return err
+			}
+
+			// Generate and print the value
+			value, err := generate.Generate(spec)
+			if err != nil {
+				return fmt.Errorf("error generating value: %v", err)
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// RegisterCommandsRecursive registers the generate command.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newGenerate())
+}
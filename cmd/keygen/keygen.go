@@ -0,0 +1,78 @@
+package keygen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"secretify-cli/internal/paths"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+)
+
+func newKeygen() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an identity to receive secrets encrypted to a public key",
+		Long: `Generate an X25519 identity to receive secrets encrypted to a public key.
+
+The private key is written to the output file, the public key is printed and
+can be shared with teammates to be used with create --recipient.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Get flags
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				configDir, err := paths.ConfigDir()
+				if err != nil {
+					return err
+				}
+				output = filepath.Join(configDir, "id")
+			}
+			force, err := cmd.Flags().GetBool("force")
+			if err != nil {
+				return err
+			}
+
+			// Refuse to overwrite an existing identity
+			if _, err := os.Stat(output); err == nil && !force {
+				return fmt.Errorf("identity %s already exists. Use --force to overwrite it", output)
+			}
+
+			// Generate identity
+			identity, err := age.GenerateX25519Identity()
+			if err != nil {
+				return fmt.Errorf("could not generate identity: %v", err)
+			}
+
+			// Write identity
+			err = os.MkdirAll(filepath.Dir(output), 0700)
+			if err != nil {
+				return err
+			}
+			content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+			err = os.WriteFile(output, []byte(content), 0600)
+			if err != nil {
+				return fmt.Errorf("could not write identity: %v", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Identity written to %s\n", output)
+			fmt.Println(identity.Recipient().String())
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "Identity file (default ~/.config/secretify/id)")
+	cmd.Flags().Bool("force", false, "Overwrite an existing identity")
+	return cmd
+}
+
+// RegisterCommandsRecursive registers the keygen command.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newKeygen())
+}
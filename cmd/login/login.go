@@ -1,9 +1,15 @@
 package login
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
 
 	"secretify-cli/internal"
+	"secretify-cli/internal/config"
 	"secretify-cli/internal/creds"
 	secretifyclient "secretify-cli/pkg/client"
 
@@ -15,58 +21,192 @@ import (
 
 func newLogin() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:           "login [URL]",
-		Short:         "Login with username and password",
+		Use:   "login [URL]",
+		Short: "Login with username and password",
+		Long: `Login with username and password.
+
+The identity provider is discovered from the server or selected with
+--provider, either by the name advertised by the server or by its type:
+
+  microsoftonline  client ID and secret of an app registration as username
+                   and password (default if the server advertises none)
+  local            username and password of a local account
+  token            static API token, see --token
+  oidc             interactive login at an OpenID Connect provider such as
+                   Keycloak or Okta, or at the server itself
+
+Interactive logins use --device, which prints a code to enter on another
+device, or --browser, which opens the login page in the browser. They store
+a refresh token instead of a secret.
+
+With --pin, the public key pins are stored with the credentials and
+connections to the instance fail unless it presents one of the keys. Pins
+are kept when logging in to the same instance again without --pin.`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Check if URL is provided
-			if len(args) == 0 {
-				return fmt.Errorf("no url as argument provided")
+			// Get flags
+			profile, err := cmd.Flags().GetString("profile")
+			if err != nil {
+				return err
+			}
+
+			// Check if URL is provided, otherwise take it from the configuration
+			url := ""
+			if len(args) > 0 {
+				url = args[0]
+			} else {
+				cfg, err := config.Load(creds.ResolveProfile(profile))
+				if err != nil {
+					return fmt.Errorf("config: %v", err)
+				}
+				url, _, err = cfg.Lookup("url")
+				if err != nil {
+					return fmt.Errorf("config: %v", err)
+				}
 			}
-			url := args[0]
 			if url == "" {
 				return fmt.Errorf("no url as argument provided")
 			}
-
-			// Retrieve username from flags
-			username, err := cmd.Flags().GetString("username")
+			providerName, err := cmd.Flags().GetString("provider")
+			if err != nil {
+				return err
+			}
+			device, err := cmd.Flags().GetBool("device")
 			if err != nil {
 				return err
 			}
-			if username == "" {
-				return fmt.Errorf("no username provided")
+			browser, err := cmd.Flags().GetBool("browser")
+			if err != nil {
+				return err
 			}
+			if device && browser {
+				return fmt.Errorf("either --device or --browser can be provided")
+			}
+			interactive := device || browser
+			pins, err := cmd.Flags().GetStringArray("pin")
+			if err != nil {
+				return err
+			}
+
+			// Keep the pinned public keys of the instance on login again
+			if len(pins) == 0 {
+				if previous, err := creds.GetCredentials(profile); err == nil && previous.URL == url {
+					pins = previous.Pins
+				}
+			}
+			c := &creds.Credentials{Profile: profile, URL: url, Pins: pins}
 
-			// Retrieve password from flags or prompt if not provided
-			password, err := cmd.Flags().GetString("password")
+			// Select the identity provider
+			client := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), "")
+			client.Client, err = creds.HTTPClient(c)
 			if err != nil {
 				return err
 			}
-			if password == "" {
-				// Prompt for password
-				fmt.Print("Enter Password: ")
+			provider, err := resolveProvider(client, providerName, interactive)
+			if err != nil {
+				return err
+			}
+			if interactive != (provider.Type == secretifyclient.ProviderOIDC) {
+				if interactive {
+					return fmt.Errorf("provider %s does not support interactive login", provider.Type)
+				}
+				// Login at OpenID Connect providers on another device by default
+				device = true
+			}
 
-				bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+			c.Provider = provider.Name
+			switch provider.Type {
+			case secretifyclient.ProviderOIDC:
+				oauth, err := provider.OAuth(client)
 				if err != nil {
-					return fmt.Errorf("error reading password from input: %v", err)
+					return fmt.Errorf("could not discover identity provider: %v", err)
 				}
-				fmt.Print("\n")
+				var token *secretifyclient.TokenResponse
+				if device {
+					c.Method = creds.MethodDevice
+					token, err = loginDevice(cmd.Context(), oauth)
+				} else {
+					c.Method = creds.MethodBrowser
+					token, err = loginBrowser(cmd.Context(), oauth)
+				}
+				if err != nil {
+					return fmt.Errorf("could not authenticate: %v", err)
+				}
+				if token.RefreshToken == "" {
+					return fmt.Errorf("could not authenticate: server issued no refresh token")
+				}
+				c.Issuer = provider.Issuer
+				c.ClientID = provider.ClientID
+				c.SetToken(token, time.Now())
+
+			case secretifyclient.ProviderToken:
+				// Retrieve token from flags or prompt if not provided
+				token, err := cmd.Flags().GetString("token")
+				if err != nil {
+					return err
+				}
+				if token == "" {
+					token, err = readPassword("Enter API Token: ")
+					if err != nil {
+						return err
+					}
+				}
+				c.Method = creds.MethodToken
+				c.Password = token
 
-				password = string(bytePassword)
+				// Verify the token
+				client.AccessToken = token
+				err = client.CheckAuth()
+				if err != nil {
+					return fmt.Errorf("could not authenticate: %v", err)
+				}
+
+			case secretifyclient.ProviderEntra, secretifyclient.ProviderLocal:
+				// Retrieve username from flags
+				username, err := cmd.Flags().GetString("username")
+				if err != nil {
+					return err
+				}
+				if username == "" {
+					return fmt.Errorf("no username provided")
+				}
+
+				// Retrieve password from flags or prompt if not provided
+				password, err := cmd.Flags().GetString("password")
+				if err != nil {
+					return err
+				}
 				if password == "" {
-					return fmt.Errorf("no password provided")
+					password, err = readPassword("Enter Password: ")
+					if err != nil {
+						return err
+					}
 				}
-			}
+				c.Method = creds.MethodClientCredentials
+				if provider.Type == secretifyclient.ProviderLocal {
+					c.Method = creds.MethodPassword
+				}
+				c.Username = username
+				c.Password = password
 
-			// Authenticate user
-			_, err = secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), "").Login(username, password)
-			if err != nil {
-				return fmt.Errorf("could not authenticate: %v", err)
+				// Authenticate user
+				authenticator, err := creds.Authenticator(c)
+				if err != nil {
+					return err
+				}
+				token, err := authenticator.Authenticate()
+				if err != nil {
+					return fmt.Errorf("could not authenticate: %v", err)
+				}
+				c.SetToken(token, time.Now())
+
+			default:
+				return fmt.Errorf("unsupported provider type %q", provider.Type)
 			}
 
 			// Store credentials
-			err = creds.StoreCredentials(url, username, password)
+			err = creds.StoreCredentials(c)
 			if err != nil {
 				return fmt.Errorf("could not store credentials: %v", err)
 			}
@@ -78,9 +218,109 @@ func newLogin() *cobra.Command {
 	cmd.Flags().StringP("username", "u", "", "Username")
 	cmd.Flags().StringP("password", "p", "", "Password")
 	cmd.Flags().String("password-stdin", "", "Password from stdin")
+	cmd.Flags().String("token", "", "API token for the token provider")
+	cmd.Flags().String("provider", "", "Identity provider by name or type: microsoftonline, local, token or oidc")
+	cmd.Flags().Bool("device", false, "Login with a code entered on another device")
+	cmd.Flags().Bool("browser", false, "Login in the browser")
+	cmd.Flags().StringArray("pin", nil, "Accept only servers presenting this public key, e.g. sha256/BASE64, repeat for backup keys")
 	return cmd
 }
 
+// resolveProvider selects the identity provider by name or type among the
+// providers advertised by the server. Without a name, the first advertised
+// provider is used, or the first OpenID Connect provider for interactive
+// logins. Servers advertising no providers support the types only.
+func resolveProvider(client *secretifyclient.HTTP, name string, interactive bool) (*secretifyclient.Provider, error) {
+	metadata, err := client.GetAuthMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve identity providers: %v", err)
+	}
+
+	lookup := name
+	if lookup == "" && interactive {
+		lookup = secretifyclient.ProviderOIDC
+	}
+	if metadata != nil {
+		if p, ok := metadata.FindProvider(lookup); ok {
+			return p, nil
+		}
+	}
+
+	switch lookup {
+	case "":
+		return &secretifyclient.Provider{Type: secretifyclient.ProviderEntra}, nil
+	case secretifyclient.ProviderEntra, secretifyclient.ProviderOIDC, secretifyclient.ProviderLocal, secretifyclient.ProviderToken:
+		return &secretifyclient.Provider{Type: lookup}, nil
+	default:
+		return nil, fmt.Errorf("identity provider %q is not offered by the server", name)
+	}
+}
+
+// readPassword prompts for a password without echoing it.
+func readPassword(label string) (string, error) {
+	fmt.Print(label)
+
+	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return "", fmt.Errorf("error reading password from input: %v", err)
+	}
+	fmt.Print("\n")
+
+	password := string(bytePassword)
+	if password == "" {
+		return "", fmt.Errorf("no password provided")
+	}
+	return password, nil
+}
+
+// browserLoginTimeout is the time to complete a browser login.
+const browserLoginTimeout = 5 * time.Minute
+
+// loginDevice runs the device authorization flow, printing the code the user
+// has to enter and polling until the login is approved.
+func loginDevice(ctx context.Context, oauth *secretifyclient.OAuth) (*secretifyclient.TokenResponse, error) {
+	da, err := oauth.AuthorizeDevice()
+	if err != nil {
+		return nil, err
+	}
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "Open %s and confirm the code %s\n", da.VerificationURIComplete, da.UserCode)
+	} else {
+		fmt.Fprintf(os.Stderr, "Open %s and enter the code %s\n", da.VerificationURI, da.UserCode)
+	}
+	fmt.Fprintln(os.Stderr, "Waiting for login...")
+	return oauth.PollDeviceToken(ctx, da)
+}
+
+// loginBrowser runs the authorization code flow with PKCE in the browser.
+func loginBrowser(ctx context.Context, oauth *secretifyclient.OAuth) (*secretifyclient.TokenResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, browserLoginTimeout)
+	defer cancel()
+
+	return oauth.LoginBrowser(ctx, func(authURL string) error {
+		fmt.Fprintf(os.Stderr, "Opening the browser, if it does not open visit:\n%s\n", authURL)
+		if err := openBrowser(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open the browser: %v\n", err)
+		}
+		fmt.Fprintln(os.Stderr, "Waiting for login...")
+		return nil
+	})
+}
+
+// openBrowser opens the URL in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
 func RegisterCommandsRecursive(parent *cobra.Command) {
 	parent.AddCommand(newLogin())
 }
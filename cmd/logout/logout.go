@@ -2,6 +2,7 @@ package logout
 
 import (
 	"fmt"
+	"os"
 
 	"secretify-cli/internal/creds"
 
@@ -10,20 +11,71 @@ import (
 
 func newLogout() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:           "logout",
-		Short:         "Log out",
+		Use:   "logout",
+		Short: "Log out",
+		Long: `Log out of the profile, or of all profiles with --all.
+
+The refresh or access token is revoked at the server if it supports it, and
+only the credentials of the profile are removed from the keyring and the
+.netrc file.`,
+		Args:          cobra.NoArgs,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Delete stored credentials
-			err := creds.DeleteCredentials()
+			// Get flags
+			profile, err := cmd.Flags().GetString("profile")
+			if err != nil {
+				return err
+			}
+			all, err := cmd.Flags().GetBool("all")
 			if err != nil {
-				return fmt.Errorf("could not delete credentials: %v", err)
+				return err
+			}
+			if all && profile != "" {
+				return fmt.Errorf("either --profile or --all can be provided")
+			}
+
+			// Select the profiles to log out of
+			profiles := []string{creds.ResolveProfile(profile)}
+			if all {
+				profiles, err = creds.Profiles()
+				if err != nil {
+					return err
+				}
+				if len(profiles) == 0 {
+					fmt.Println("No credentials stored")
+					return nil
+				}
+			}
+
+			for _, p := range profiles {
+				// Revoke the token, failures do not prevent deleting the credentials
+				if c, err := creds.GetCredentials(p); err == nil {
+					revoked, err := creds.Revoke(c)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Could not revoke token of profile %s: %v\n", p, err)
+					} else if revoked {
+						fmt.Printf("Revoked token of profile %s at %s\n", p, c.URL)
+					}
+				}
+
+				// Delete stored credentials
+				removed, err := creds.DeleteCredentials(p)
+				for _, backend := range removed {
+					fmt.Printf("Removed credentials of profile %s from %s\n", p, backend)
+				}
+				if err != nil {
+					return fmt.Errorf("could not delete credentials: %v", err)
+				}
+				if len(removed) == 0 {
+					fmt.Printf("No credentials stored for profile %s\n", p)
+				}
 			}
 			fmt.Println("Logout Succeeded")
 			return nil
 		},
 	}
+	cmd.Flags().Bool("all", false, "Log out of all profiles")
 	return cmd
 }
 
@@ -1,23 +1,32 @@
 package reveal
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
+	"os"
 	"secretify-cli/internal"
 	"secretify-cli/internal/creds"
+	"secretify-cli/internal/util"
 	secretifyclient "secretify-cli/pkg/client"
 	"secretify-cli/pkg/crypto"
-	"strings"
+	secretlink "secretify-cli/pkg/link"
+	"secretify-cli/pkg/shamir"
 
+	"filippo.io/age"
 	"github.com/spf13/cobra"
 )
 
 func newReveal() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:           "reveal",
-		Short:         "Reveal a secret",
+		Use:   "reveal [LINK]",
+		Short: "Reveal a secret",
+		Long: `Reveal a secret.
+
+The secret is retrieved anonymously from the instance the link points to,
+no login is required. Credentials are only used if --auth is set or the
+server demands authentication, and only if the link points to the logged-in
+instance or an origin allowed with --allow-origin.`,
+		Args:          cobra.MaximumNArgs(1),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -26,6 +35,12 @@ func newReveal() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if len(args) > 0 {
+				if link != "" {
+					return fmt.Errorf("link provided both as argument and with --link")
+				}
+				link = args[0]
+			}
 			identifier, err := cmd.Flags().GetString("identifier")
 			if err != nil {
 				return err
@@ -34,67 +49,224 @@ func newReveal() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			// Check if either link or identifier with key is provided
-			if link == "" && (identifier == "" || key == "") {
+			shares, err := cmd.Flags().GetStringArray("share")
+			if err != nil {
+				return err
+			}
+			identityFile, err := cmd.Flags().GetString("identity")
+			if err != nil {
+				return err
+			}
+			auth, err := cmd.Flags().GetBool("auth")
+			if err != nil {
+				return err
+			}
+			allowedOrigins, err := cmd.Flags().GetStringArray("allow-origin")
+			if err != nil {
+				return err
+			}
+			// Check if either link or identifier with key or shares is provided
+			if link == "" && (identifier == "" || (key == "" && len(shares) == 0)) {
 				return fmt.Errorf("no link nor identifier with key provided")
 			}
+			if len(shares) > 0 && key != "" {
+				return fmt.Errorf("either key or shares can be provided")
+			}
 
-			// If link is provided, parse it to get identifier and key
+			// Retrieve credentials of the logged-in instance, which are optional
+			profile, err := cmd.Flags().GetString("profile")
+			if err != nil {
+				return err
+			}
+			credentials, credsErr := creds.GetCredentials(profile)
+			var url string
+			if credsErr == nil {
+				url = credentials.URL
+			}
+
+			// If link is provided, parse it to get instance, identifier and key
+			var baseURL string
+			var decodedKey []byte
 			if link != "" {
-				parsedURL, err := url.Parse(link)
+				parsedLink, err := secretlink.Parse(link)
 				if err != nil {
-					return fmt.Errorf("error parsing Link %v", err)
+					return err
+				}
+				if parsedLink.Key != nil && len(shares) > 0 {
+					return fmt.Errorf("link contains a key, shares are not required")
+				}
+				baseURL = parsedLink.BaseURL
+				identifier = parsedLink.Identifier
+				decodedKey = parsedLink.Key
+				crypto.Lock(decodedKey)
+				defer crypto.Destroy(decodedKey)
+			} else {
+				// Without link the secret is retrieved from the logged-in instance
+				if credsErr != nil {
+					return fmt.Errorf("no link provided and not logged in: %v", credsErr)
+				}
+				baseURL = url
+				if err := secretlink.ValidateIdentifier(identifier); err != nil {
+					return err
 				}
+				if key != "" {
+					decodedKey, err = secretlink.DecodeKey(key)
+					if err != nil {
+						return err
+					}
+					crypto.Lock(decodedKey)
+					defer crypto.Destroy(decodedKey)
+				}
+			}
 
-				// Extract identifier and key
-				pathSegments := strings.Split(parsedURL.Path, "/")
-				identifier = pathSegments[len(pathSegments)-1]
-				key = parsedURL.Fragment
+			// Reconstruct the key from shares
+			if len(shares) > 0 {
+				decodedKey, err = combineShares(shares)
+				if err != nil {
+					return err
+				}
+				crypto.Lock(decodedKey)
+				defer crypto.Destroy(decodedKey)
 			}
 
-			// Authenticate (optional)
-			var token string
-			url, username, password, err := creds.GetCredentials()
-			if err != nil {
-				return fmt.Errorf("authentication: %v", err)
+			// Load the identities to unwrap a key encrypted to recipients
+			var identities []age.Identity
+			if identityFile != "" {
+				if decodedKey != nil {
+					return fmt.Errorf("key provided, an identity is not required")
+				}
+				identities, err = crypto.LoadIdentities(identityFile)
+				if err != nil {
+					return err
+				}
+			}
+			if decodedKey == nil && identities == nil {
+				return fmt.Errorf("link contains no key. Use --share to provide the shares of a split key or --identity if the secret was encrypted to your public key")
 			}
-			token, _ = secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), token).Login(username, password)
 
-			// Reveal secret
-			encryptedMap, err := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, url), token).Reveal(identifier)
-			if err != nil {
-				return err
+			// Authenticate against the logged-in instance, only if the link
+			// points to the same instance
+			authenticate := func() (secretifyclient.TokenSource, error) {
+				if credsErr != nil {
+					return nil, fmt.Errorf("authentication: %v", credsErr)
+				}
+				if !isAllowedOrigin(baseURL, url, allowedOrigins) {
+					return nil, fmt.Errorf("credentials of %s are not sent to %s. Use --allow-origin to allow it", url, baseURL)
+				}
+				return creds.TokenSource(credentials), nil
+			}
+
+			// Pin the public keys of the logged-in instance, connections to
+			// other instances are not affected
+			aClient := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, baseURL), "")
+			if credsErr == nil {
+				aClient.Client, err = creds.HTTPClient(credentials)
+				if err != nil {
+					return err
+				}
+			}
+			if auth {
+				aClient.TokenSource, err = authenticate()
+				if err != nil {
+					return err
+				}
 			}
 
-			// Decrypt values
-			decodedKey, err := base64.RawURLEncoding.DecodeString(key)
+			// Reveal secret, authenticating only if the server demands it.
+			// Access tokens rejected by the server are renewed once.
+			encryptedMap, err := aClient.Reveal(identifier)
+			var statusErr *secretifyclient.StatusError
+			if err != nil && aClient.TokenSource == nil && errors.As(err, &statusErr) && statusErr.IsUnauthorized() {
+				var authErr error
+				aClient.TokenSource, authErr = authenticate()
+				if authErr != nil {
+					return fmt.Errorf("%v: %v", err, authErr)
+				}
+				encryptedMap, err = aClient.Reveal(identifier)
+			}
 			if err != nil {
 				return err
 			}
-			var decryptedMap = make(map[string]string, len(encryptedMap))
-			for k, v := range encryptedMap {
-				decrypted, err := crypto.DecryptStringFromDataURL(v, decodedKey)
+
+			// Unwrap the key with the identities
+			wrappedKey, wrapped := encryptedMap[crypto.WrappedKeyField]
+			if decodedKey == nil {
+				if !wrapped {
+					return fmt.Errorf("secret was not encrypted to a public key")
+				}
+				decodedKey, err = crypto.UnwrapKey(wrappedKey, identities)
 				if err != nil {
-					return fmt.Errorf("decryption error %v", err)
+					return fmt.Errorf("could not unwrap key: %v", err)
 				}
-				decryptedMap[k] = decrypted
+				crypto.Lock(decodedKey)
+				defer crypto.Destroy(decodedKey)
 			}
 
-			// Output decrypted map as JSON
-			b, err := json.Marshal(decryptedMap)
+			// Verify the manifest before decrypting values, reserved fields
+			// such as the wrapped key are not decrypted
+			decryptedMap, err := crypto.DecryptDataMap(encryptedMap, decodedKey)
 			if err != nil {
-				return err
+				return fmt.Errorf("decryption error %v", err)
 			}
-			fmt.Println(string(b))
-			return nil
+			defer crypto.WipeDataMap(decryptedMap)
+
+			// Output decrypted map as JSON
+			return util.WriteJSONObject(os.Stdout, decryptedMap)
 		},
 	}
 	cmd.Flags().String("link", "", "Link of the secret")
 	cmd.Flags().String("identifier", "", "Identifier of the secret")
 	cmd.Flags().String("key", "", "Key of the secret")
+	cmd.Flags().StringArray("share", nil, "Share of a split key, repeat for each share")
+	cmd.Flags().String("identity", "", "Identity file to unwrap a key encrypted to your public key, e.g. ~/.config/secretify/id")
+	cmd.Flags().Bool("auth", false, "Authenticate with the stored credentials")
+	cmd.Flags().StringArray("allow-origin", nil, "Allow sending credentials to another origin of the logged-in instance, e.g. https://secretify.example.com")
 	return cmd
 }
 
+// isAllowedOrigin reports whether the credentials of the logged-in instance
+// may be sent to the instance at baseURL, which is the case for the logged-in
+// instance itself and explicitly allowed origins.
+func isAllowedOrigin(baseURL, loggedInURL string, allowedOrigins []string) bool {
+	if secretlink.SameOrigin(baseURL, loggedInURL) {
+		return true
+	}
+	for _, o := range allowedOrigins {
+		if secretlink.SameOrigin(baseURL, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// combineShares parses the given shares and reconstructs the key.
+func combineShares(encodedShares []string) ([]byte, error) {
+	shares := make([]shamir.Share, 0, len(encodedShares))
+	for i, s := range encodedShares {
+		share, err := shamir.ParseShare(s)
+		if err != nil {
+			return nil, fmt.Errorf("share %d: %v", i+1, err)
+		}
+		shares = append(shares, share)
+	}
+
+	defer func() {
+		for _, share := range shares {
+			crypto.Wipe(share.Data)
+		}
+	}()
+
+	key, err := shamir.CombineShares(shares)
+	if err != nil {
+		return nil, fmt.Errorf("could not reconstruct key: %v", err)
+	}
+	if len(key) != secretlink.KeySize {
+		crypto.Wipe(key)
+		return nil, fmt.Errorf("could not reconstruct key: invalid key size %d", len(key))
+	}
+	return key, nil
+}
+
 func RegisterCommandsRecursive(parent *cobra.Command) {
 	parent.AddCommand(newReveal())
 }
@@ -0,0 +1,130 @@
+package seal
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"secretify-cli/pkg/crypto"
+	secretlink "secretify-cli/pkg/link"
+
+	"github.com/spf13/cobra"
+)
+
+func newSeal() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "seal",
+		Short: "Encrypt a file offline without a server",
+		Long: `Encrypt a file offline without a server.
+
+The encrypted blob uses the same format as the values of a secret and can
+later be uploaded with create --sealed. If --out is provided, the key is
+printed to stdout, otherwise the blob is printed to stdout and the key to
+stderr. Send the key separately from the blob.`,
+		Example: `  secretify seal --in id_rsa --out id_rsa.sealed
+  secretify unseal --key KEY --in id_rsa.sealed --out id_rsa`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Get flags
+			in, err := cmd.Flags().GetString("in")
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString("out")
+			if err != nil {
+				return err
+			}
+			opts, err := sealOptions(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Read plaintext
+			plaintext, err := readInput(in)
+			if err != nil {
+				return fmt.Errorf("could not read input: %v", err)
+			}
+			defer crypto.Wipe(plaintext)
+
+			// Generate key and encrypt
+			key, err := crypto.GenerateEncryptionKeyString()
+			if err != nil {
+				return fmt.Errorf("error key: %v", err)
+			}
+			defer crypto.Destroy(key)
+			blob, err := crypto.Seal(plaintext, key, opts)
+			if err != nil {
+				return fmt.Errorf("error encryption: %v", err)
+			}
+
+			// Write blob and print key
+			if out == "" || out == "-" {
+				fmt.Println(blob)
+				fmt.Fprintf(os.Stderr, "Key: %s\n", secretlink.EncodeKey(key))
+				return nil
+			}
+			err = os.WriteFile(out, []byte(blob+"\n"), 0600)
+			if err != nil {
+				return fmt.Errorf("could not write output: %v", err)
+			}
+			fmt.Println(secretlink.EncodeKey(key))
+			return nil
+		},
+	}
+	cmd.Flags().String("in", "-", "File to encrypt, - for stdin")
+	cmd.Flags().String("out", "", "File to write the encrypted blob to (default stdout)")
+	cmd.Flags().String("cipher", string(crypto.CipherAESGCM), "Cipher to encrypt with: aes-256-gcm or xchacha20-poly1305")
+	cmd.Flags().String("padding", "none", "Pad to hide the length: none, padme or pow2")
+	cmd.Flags().String("compression", "none", "Compress files larger than 1 KiB before encryption: none or deflate")
+	return cmd
+}
+
+// sealOptions retrieves the encryption options from the flags.
+func sealOptions(cmd *cobra.Command) (crypto.Options, error) {
+	// Blobs are legacy values unless options are set, create --sealed marks
+	// them as part of a manifest if the secret has one
+	var opts crypto.Options
+
+	cipherName, err := cmd.Flags().GetString("cipher")
+	if err != nil {
+		return opts, err
+	}
+	opts.Cipher, err = crypto.ParseCipher(cipherName)
+	if err != nil {
+		return opts, err
+	}
+
+	paddingName, err := cmd.Flags().GetString("padding")
+	if err != nil {
+		return opts, err
+	}
+	opts.Padding, err = crypto.ParsePadding(paddingName)
+	if err != nil {
+		return opts, err
+	}
+
+	compressionName, err := cmd.Flags().GetString("compression")
+	if err != nil {
+		return opts, err
+	}
+	opts.Compression, err = crypto.ParseCompression(compressionName)
+	if err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// readInput reads the file or stdin if path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// RegisterCommandsRecursive registers the seal command.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newSeal())
+}
@@ -0,0 +1,87 @@
+package unseal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"secretify-cli/pkg/crypto"
+	secretlink "secretify-cli/pkg/link"
+
+	"github.com/spf13/cobra"
+)
+
+func newUnseal() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "unseal",
+		Short:         "Decrypt a blob encrypted with seal",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Get flags
+			key, err := cmd.Flags().GetString("key")
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return fmt.Errorf("no key provided")
+			}
+			in, err := cmd.Flags().GetString("in")
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString("out")
+			if err != nil {
+				return err
+			}
+
+			// Decode key
+			decodedKey, err := secretlink.DecodeKey(key)
+			if err != nil {
+				return err
+			}
+			crypto.Lock(decodedKey)
+			defer crypto.Destroy(decodedKey)
+
+			// Read blob
+			var blob []byte
+			if in == "-" {
+				blob, err = io.ReadAll(os.Stdin)
+			} else {
+				blob, err = os.ReadFile(in)
+			}
+			if err != nil {
+				return fmt.Errorf("could not read input: %v", err)
+			}
+
+			// Decrypt
+			plaintext, err := crypto.Open(strings.TrimSpace(string(blob)), decodedKey)
+			if err != nil {
+				return fmt.Errorf("decryption error %v", err)
+			}
+			defer crypto.Wipe(plaintext)
+
+			// Write plaintext
+			if out == "" || out == "-" {
+				_, err = os.Stdout.Write(plaintext)
+				return err
+			}
+			err = os.WriteFile(out, plaintext, 0600)
+			if err != nil {
+				return fmt.Errorf("could not write output: %v", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("key", "", "Key printed by seal")
+	cmd.Flags().String("in", "-", "File containing the encrypted blob, - for stdin")
+	cmd.Flags().String("out", "", "File to write the decrypted content to (default stdout)")
+	return cmd
+}
+
+// RegisterCommandsRecursive registers the unseal command.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newUnseal())
+}
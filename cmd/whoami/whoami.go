@@ -0,0 +1,168 @@
+package whoami
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"secretify-cli/internal"
+	"secretify-cli/internal/creds"
+	secretifyclient "secretify-cli/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func newWhoami(use string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Show the instance and identity in use",
+		Long: `Show the instance and identity the stored credentials of the profile
+belong to, where they are stored and when the access token expires.
+
+With --check the credentials are validated against the server, renewing the
+access token if required. The command fails if they are invalid.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Get flags
+			profile, err := cmd.Flags().GetString("profile")
+			if err != nil {
+				return err
+			}
+			check, err := cmd.Flags().GetBool("check")
+			if err != nil {
+				return err
+			}
+
+			// Retrieve credentials of the profile
+			profile = creds.ResolveProfile(profile)
+			c, err := creds.GetCredentials(profile)
+			if err != nil {
+				return fmt.Errorf("not logged in with profile %s: %v", profile, err)
+			}
+
+			// Validate the credentials against the server
+			if check {
+				aClient := secretifyclient.NewHTTPWithTokenSource(fmt.Sprintf(internal.APIURL, c.URL), creds.TokenSource(c))
+				aClient.Client, err = creds.HTTPClient(c)
+				if err != nil {
+					return err
+				}
+				if err := aClient.CheckAuth(); err != nil {
+					return fmt.Errorf("credentials of profile %s are invalid: %v", profile, err)
+				}
+
+				// Show the renewed token
+				if renewed, err := creds.GetCredentials(profile); err == nil {
+					c = renewed
+				}
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Profile:\t%s\n", c.Profile)
+			fmt.Fprintf(w, "Instance:\t%s\n", c.URL)
+			switch c.AuthMethod() {
+			case creds.MethodClientCredentials:
+				fmt.Fprintf(w, "Client ID:\t%s\n", c.Username)
+			case creds.MethodToken:
+				fmt.Fprintf(w, "Username:\t%s\n", orDash(tokenSubject(c.Password)))
+			default:
+				username := c.Username
+				if username == "" {
+					username = tokenSubject(c.AccessToken)
+				}
+				fmt.Fprintf(w, "Username:\t%s\n", orDash(username))
+			}
+			fmt.Fprintf(w, "Method:\t%s\n", c.AuthMethod())
+			if c.Provider != "" {
+				fmt.Fprintf(w, "Provider:\t%s\n", c.Provider)
+			}
+			if c.Issuer != "" {
+				fmt.Fprintf(w, "Issuer:\t%s\n", c.Issuer)
+			}
+			for _, pin := range c.Pins {
+				fmt.Fprintf(w, "Pinned key:\t%s\n", pin)
+			}
+			fmt.Fprintf(w, "Backend:\t%s\n", c.Backend)
+			fmt.Fprintf(w, "Token expiry:\t%s\n", tokenExpiry(c, time.Now()))
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if check {
+				fmt.Println("Credentials valid")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("check", false, "Validate the credentials against the server")
+	return cmd
+}
+
+// tokenExpiry describes when the stored access token expires.
+func tokenExpiry(c *creds.Credentials, now time.Time) string {
+	switch {
+	case c.AuthMethod() == creds.MethodToken:
+		return "never"
+	case c.AccessToken == "":
+		return "no token"
+	case c.ExpiresAt == 0:
+		return "unknown"
+	}
+	expiry := time.Unix(c.ExpiresAt, 0)
+	if !expiry.After(now) {
+		return fmt.Sprintf("%s (expired)", expiry.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (in %s)", expiry.Format(time.RFC3339), expiry.Sub(now).Round(time.Second))
+}
+
+// tokenSubject returns the user name claimed by a JWT access token. The
+// token is not verified, the name is only displayed.
+func tokenSubject(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		Subject           string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	for _, v := range []string{claims.PreferredUsername, claims.Email, claims.Subject} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// RegisterCommandsRecursive registers the whoami command and its alias auth status.
+func RegisterCommandsRecursive(parent *cobra.Command) {
+	parent.AddCommand(newWhoami("whoami"))
+
+	auth := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage authentication",
+	}
+	auth.AddCommand(newWhoami("status"))
+	parent.AddCommand(auth)
+}
@@ -0,0 +1,482 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"secretify-cli/internal/expiry"
+	"secretify-cli/internal/paths"
+	secretifyclient "secretify-cli/pkg/client"
+	"secretify-cli/pkg/crypto"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the name of the project configuration file, searched
+// in the working directory and its parents.
+const ProjectFileName = ".secretify.yaml"
+
+// EnvPrefix is the prefix of environment variables overriding settings.
+const EnvPrefix = "SECRETIFY_"
+
+// Key is a setting of the configuration.
+type Key struct {
+	// Name is the name of the setting in configuration files.
+	Name string
+	// Flag is the name of the flag set from the setting, empty if none.
+	Flag string
+	// Default is the value if the setting is not configured.
+	Default string
+	// Usage describes the setting.
+	Usage string
+	// UserOnly reports whether the setting affects where requests are sent
+	// or how they are secured. Such settings are rejected in project files,
+	// which may come from untrusted checkouts.
+	UserOnly bool
+	// isPath reports whether the value is a path, which is relative to the
+	// directory of the configuration file.
+	isPath   bool
+	validate func(string) error
+}
+
+// Env returns the name of the environment variable overriding the setting.
+func (k *Key) Env() string {
+	return EnvPrefix + strings.ToUpper(k.Name)
+}
+
+// Validate checks that the value is valid for the setting.
+func (k *Key) Validate(value string) error {
+	if k.validate == nil || value == "" {
+		return nil
+	}
+	if err := k.validate(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, k.Name, err)
+	}
+	return nil
+}
+
+// Keys are all settings of the configuration.
+var Keys = []Key{
+	{Name: "url", Usage: "URL of the instance used by login without URL, may be unix:///path of a socket", UserOnly: true, validate: validateInstanceURL},
+	{Name: "link_url", Flag: "link-url", Usage: "Base URL of printed links, defaults to the URL of the instance", UserOnly: true, validate: validateURL},
+	{Name: "expires_at", Flag: "expiresAt", Default: "24h", Usage: "Expiration of created secrets", validate: validateExpiry},
+	{Name: "views", Flag: "views", Default: "1", Usage: "Number of views of created secrets", validate: validatePositive},
+	{Name: "destroyable", Flag: "destroyable", Default: "false", Usage: "Whether recipients can destroy created secrets", validate: validateBool},
+	{Name: "cipher", Flag: "cipher", Default: string(crypto.CipherAESGCM), Usage: "Cipher of created and sealed values: aes-256-gcm or xchacha20-poly1305", validate: validateCipher},
+	{Name: "manifest", Flag: "manifest", Default: "false", Usage: "Whether created secrets are authenticated with a manifest, which the web app cannot reveal yet", validate: validateBool},
+	{Name: "output", Flag: "output", Default: "text", Usage: "Output format of create: text or json", validate: validateOutput},
+	{Name: "timeout", Flag: "timeout", Default: "30s", Usage: "Timeout of requests to the server", validate: validateDuration},
+	{Name: "proxy", Flag: "proxy", Usage: "HTTP(S) or SOCKS5 proxy URL for requests to the server", UserOnly: true, validate: secretifyclient.ValidateProxy},
+	{Name: "no_proxy", Flag: "no-proxy", Usage: "Comma-separated hosts reached without proxy", UserOnly: true},
+	{Name: "debug", Flag: "debug", Default: "false", Usage: "Log requests to the server to stderr, secrets are redacted", UserOnly: true, validate: validateBool},
+	{Name: "cacert", Flag: "cacert", Usage: "PEM file of additionally trusted CA certificates", UserOnly: true, isPath: true},
+	{Name: "cert", Flag: "cert", Usage: "PEM file of the client certificate for mutual TLS", UserOnly: true, isPath: true},
+	{Name: "cert_key", Flag: "cert-key", Usage: "PEM file of the key of the client certificate", UserOnly: true, isPath: true},
+}
+
+// LookupKey returns the setting with the given name.
+func LookupKey(name string) (*Key, error) {
+	for i := range Keys {
+		if Keys[i].Name == name {
+			return &Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown setting %q", name)
+}
+
+// Source is where the value of a setting is taken from.
+type Source string
+
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceProject Source = "project"
+	SourceUser    Source = "user"
+	SourceDefault Source = "default"
+)
+
+// File is a configuration file. Settings at the top level apply to all
+// profiles, settings below profiles to the named profile only.
+type File struct {
+	Path     string                       `yaml:"-"`
+	Settings map[string]string            `yaml:",inline"`
+	Profiles map[string]map[string]string `yaml:"profiles,omitempty"`
+}
+
+// lookup returns the value of the setting for the profile. Relative paths
+// are resolved against the directory of the file.
+func (f *File) lookup(profile string, k *Key) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	v, ok := f.Profiles[profile][k.Name]
+	if !ok {
+		v, ok = f.Settings[k.Name]
+	}
+	if ok && k.isPath && v != "" && !filepath.IsAbs(v) {
+		v = filepath.Join(filepath.Dir(f.Path), v)
+	}
+	return v, ok
+}
+
+// validate checks that the file only contains valid settings, and no
+// settings restricted to the user file if it is a project file.
+func (f *File) validate() error {
+	project := IsProjectFile(f.Path)
+	check := func(settings map[string]string) error {
+		for name, value := range settings {
+			k, err := LookupKey(name)
+			if err != nil {
+				return err
+			}
+			if project && k.UserOnly {
+				return userOnlyError(k)
+			}
+			if err := k.Validate(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(f.Settings); err != nil {
+		return fmt.Errorf("%s: %v", f.Path, err)
+	}
+	for profile, settings := range f.Profiles {
+		if err := check(settings); err != nil {
+			return fmt.Errorf("%s: profile %s: %v", f.Path, profile, err)
+		}
+	}
+	return nil
+}
+
+// ReadFile reads a configuration file, returning nil if it doesn't exist.
+func ReadFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	f := &File{Path: path}
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// UserFilePath returns the path of the user configuration file in the
+// configuration directory.
+func UserFilePath() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// IsProjectFile reports whether the path is a project configuration file.
+func IsProjectFile(path string) bool {
+	return filepath.Base(path) == ProjectFileName
+}
+
+// userOnlyError reports a setting restricted to the user file found in a
+// project file.
+func userOnlyError(k *Key) error {
+	return fmt.Errorf("%s cannot be set in the project file %s, set it in the user file, with %s or a flag", k.Name, ProjectFileName, k.Env())
+}
+
+// FindProjectFile returns the path of the project configuration file in the
+// directory or its nearest parent, empty if there is none.
+func FindProjectFile(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Config is the layered configuration of a profile.
+type Config struct {
+	Profile string
+	// User is the user configuration file, nil if it doesn't exist.
+	User *File
+	// Project is the project configuration file, nil if there is none.
+	Project *File
+}
+
+// Load loads the user and project configuration files for the profile.
+func Load(profile string) (*Config, error) {
+	c := &Config{Profile: profile}
+
+	userPath, err := UserFilePath()
+	if err != nil {
+		return nil, err
+	}
+	c.User, err = ReadFile(userPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if projectPath := FindProjectFile(wd); projectPath != "" {
+			c.Project, err = ReadFile(projectPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// Lookup returns the value of the setting and where it is taken from.
+// Environment variables take precedence over the project file, the project
+// file over the user file and the user file over the default.
+func (c *Config) Lookup(name string) (string, Source, error) {
+	k, err := LookupKey(name)
+	if err != nil {
+		return "", "", err
+	}
+	if v, ok := os.LookupEnv(k.Env()); ok {
+		if err := k.Validate(v); err != nil {
+			return "", "", fmt.Errorf("%s: %v", k.Env(), err)
+		}
+		return v, SourceEnv, nil
+	}
+	if v, ok := c.Project.lookup(c.Profile, k); ok {
+		return v, SourceProject, nil
+	}
+	if v, ok := c.User.lookup(c.Profile, k); ok {
+		return v, SourceUser, nil
+	}
+	return k.Default, SourceDefault, nil
+}
+
+// ApplyFlags sets the flags of the settings to the configured values unless
+// they are set on the command line, so that flags take precedence over all
+// other sources.
+func (c *Config) ApplyFlags(flags *pflag.FlagSet) error {
+	for _, k := range Keys {
+		if k.Flag == "" {
+			continue
+		}
+		f := flags.Lookup(k.Flag)
+		if f == nil || f.Changed {
+			continue
+		}
+		v, source, err := c.Lookup(k.Name)
+		if err != nil {
+			return err
+		}
+		if source == SourceDefault {
+			continue
+		}
+		if err := f.Value.Set(v); err != nil {
+			return fmt.Errorf("invalid value %q of %s from %s: %v", v, k.Name, source, err)
+		}
+	}
+	return nil
+}
+
+// Set sets the setting in the configuration file at path, for the profile
+// only if profile is not empty, preserving comments and other settings. An
+// empty value removes the setting.
+func Set(path, profile, name, value string) error {
+	k, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	if IsProjectFile(path) && k.UserOnly && value != "" {
+		return userOnlyError(k)
+	}
+	if err := k.Validate(value); err != nil {
+		return err
+	}
+
+	// Parse the file as document node to preserve comments
+	var doc yaml.Node
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) > 0 {
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: not a mapping", path)
+	}
+
+	m := root
+	if profile != "" {
+		profiles := mappingValue(root, "profiles", value != "")
+		if profiles == nil {
+			return nil
+		}
+		m = mappingValue(profiles, profile, value != "")
+		if m == nil {
+			return nil
+		}
+	}
+	if value == "" {
+		deleteMappingKey(m, name)
+	} else {
+		setMappingKey(m, name, value)
+	}
+
+	// Write the file
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// Names returns the names of all settings, sorted.
+func Names() []string {
+	names := make([]string, 0, len(Keys))
+	for _, k := range Keys {
+		names = append(names, k.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mappingValue returns the mapping node below the key, creating it if
+// requested, or nil.
+func mappingValue(m *yaml.Node, key string, create bool) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			v := m.Content[i+1]
+			if v.Kind != yaml.MappingNode {
+				if !create {
+					return nil
+				}
+				*v = yaml.Node{Kind: yaml.MappingNode}
+			}
+			return v
+		}
+	}
+	if !create {
+		return nil
+	}
+	v := &yaml.Node{Kind: yaml.MappingNode}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	return v
+}
+
+func setMappingKey(m *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1].Kind = yaml.ScalarNode
+			m.Content[i+1].Tag = ""
+			m.Content[i+1].Value = value
+			m.Content[i+1].Content = nil
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+}
+
+func deleteMappingKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func validateURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("not an absolute URL")
+	}
+	return nil
+}
+
+func validateInstanceURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == secretifyclient.UnixScheme {
+		if u.Host != "" || u.Path == "" {
+			return errors.New("expected unix:///path of a socket")
+		}
+		return nil
+	}
+	return validateURL(v)
+}
+
+func validateExpiry(v string) error {
+	_, err := expiry.Parse(v, time.Now())
+	return err
+}
+
+func validatePositive(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return errors.New("must be at least 1")
+	}
+	return nil
+}
+
+func validateBool(v string) error {
+	_, err := strconv.ParseBool(v)
+	return err
+}
+
+func validateCipher(v string) error {
+	_, err := crypto.ParseCipher(v)
+	return err
+}
+
+func validateOutput(v string) error {
+	if v != "text" && v != "json" {
+		return errors.New("must be text or json")
+	}
+	return nil
+}
+
+func validateDuration(v string) error {
+	_, err := time.ParseDuration(v)
+	return err
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProjectFileUserOnly checks that settings which affect where requests
+// are sent or how they are secured are rejected in project files.
+func TestProjectFileUserOnly(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, ProjectFileName)
+	user := filepath.Join(dir, "config.yaml")
+
+	for _, k := range Keys {
+		if !k.UserOnly {
+			continue
+		}
+		for _, content := range []string{
+			k.Name + ": x\n",
+			"profiles:\n  work:\n    " + k.Name + ": x\n",
+		} {
+			writeFile(t, project, content)
+			_, err := ReadFile(project)
+			if err == nil || !strings.Contains(err.Error(), "cannot be set in the project file") {
+				t.Errorf("project file %q: error %v, want rejection", content, err)
+			}
+		}
+		if err := Set(project, "", k.Name, "x"); err == nil || !strings.Contains(err.Error(), "cannot be set in the project file") {
+			t.Errorf("set %s in project file: error %v, want rejection", k.Name, err)
+		}
+	}
+
+	// The settings are accepted in the user file
+	writeFile(t, user, "url: https://example.com\nproxy: socks5://127.0.0.1:1080\ncacert: ca.pem\ndebug: true\n")
+	f, err := ReadFile(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.lookup("default", &Keys[0]); v != "https://example.com" {
+		t.Errorf("url = %q", v)
+	}
+
+	// Other settings are accepted in the project file
+	writeFile(t, project, "expires_at: 7d\nviews: 3\nprofiles:\n  work:\n    output: json\n")
+	if _, err := ReadFile(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(project, "work", "destroyable", "true"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "config.yaml")
+	project := filepath.Join(dir, ProjectFileName)
+	writeFile(t, user, "views: 2\nexpires_at: 1h\ntimeout: 10s\nprofiles:\n  work:\n    views: 5\n")
+	writeFile(t, project, "views: 3\n")
+	userFile, err := ReadFile(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectFile, err := ReadFile(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{Profile: "work", User: userFile, Project: projectFile}
+	t.Setenv("SECRETIFY_TIMEOUT", "5s")
+
+	tests := []struct {
+		name   string
+		value  string
+		source Source
+	}{
+		{"views", "3", SourceProject},
+		{"expires_at", "1h", SourceUser},
+		{"timeout", "5s", SourceEnv},
+		{"output", "text", SourceDefault},
+	}
+	for _, tt := range tests {
+		v, source, err := c.Lookup(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != tt.value || source != tt.source {
+			t.Errorf("Lookup(%s) = %s from %s, want %s from %s", tt.name, v, source, tt.value, tt.source)
+		}
+	}
+}
+
+// TestApplyFlags checks that configured values are applied to flags not set
+// on the command line.
+func TestApplyFlags(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "config.yaml")
+	writeFile(t, user, "cipher: xchacha20-poly1305\nviews: 3\n")
+	userFile, err := ReadFile(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{Profile: "default", User: userFile}
+
+	flags := pflag.NewFlagSet("create", pflag.ContinueOnError)
+	flags.String("cipher", "aes-256-gcm", "")
+	flags.Int("views", 1, "")
+	if err := flags.Parse([]string{"--views", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ApplyFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := flags.GetString("cipher"); v != "xchacha20-poly1305" {
+		t.Errorf("cipher = %q, want the configured xchacha20-poly1305", v)
+	}
+	if v, _ := flags.GetInt("views"); v != 5 {
+		t.Errorf("views = %d, want 5 from the command line", v)
+	}
+
+	writeFile(t, user, "cipher: rot13\n")
+	if _, err := ReadFile(user); err == nil || !strings.Contains(err.Error(), "unsupported cipher") {
+		t.Errorf("error %v, want unsupported cipher", err)
+	}
+}
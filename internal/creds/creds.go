@@ -1,18 +1,162 @@
 package creds
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	keyring "github.com/zalando/go-keyring"
+
+	secretifyclient "secretify-cli/pkg/client"
+)
+
+// Method is the way the CLI authenticates with the stored credentials.
+type Method string
+
+const (
+	// MethodClientCredentials authenticates with a client ID and secret.
+	MethodClientCredentials Method = "client_credentials"
+	// MethodDevice refreshes tokens obtained with the device authorization flow.
+	MethodDevice Method = "device"
+	// MethodBrowser refreshes tokens obtained with a browser login.
+	MethodBrowser Method = "browser"
+	// MethodPassword authenticates a local account with username and password.
+	MethodPassword Method = "password"
+	// MethodToken authenticates with a static API token.
+	MethodToken Method = "token"
+)
+
+// DefaultProfile is the profile used if none is selected.
+const DefaultProfile = "default"
+
+// ProfileEnv is the environment variable selecting the profile.
+const ProfileEnv = "SECRETIFY_PROFILE"
+
+var profilePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateProfile checks that the profile name only consists of letters,
+// digits, underscores and hyphens.
+func ValidateProfile(profile string) error {
+	if !profilePattern.MatchString(profile) {
+		return fmt.Errorf("invalid profile name %q", profile)
+	}
+	return nil
+}
+
+// ResolveProfile returns the given profile, falling back to the profile of
+// the environment and DefaultProfile.
+func ResolveProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if profile := os.Getenv(ProfileEnv); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// Backend is where credentials are stored.
+type Backend string
+
+const (
+	// BackendKeyring is the keyring of the operating system.
+	BackendKeyring Backend = "keyring"
+	// BackendNetrc is the personalized .netrc file in the state directory.
+	BackendNetrc Backend = "netrc"
 )
 
-// StoreCredentials stores credentials either in keyring if available else
-// as fallback it uses a personalized ~/.secretify/.netrc file.
-func StoreCredentials(service, username, password string) error {
-	err := keyringSet(service, username, password)
+// Credentials are the stored credentials of the logged-in instance.
+type Credentials struct {
+	// Profile is the name of the profile the credentials are stored for.
+	Profile string `json:"-"`
+	// Backend is where the credentials were loaded from.
+	Backend Backend `json:"-"`
+	// URL is the URL of the instance.
+	URL string `json:"api_url"`
+	// Method is the authentication method, empty for MethodClientCredentials.
+	Method Method `json:"method,omitempty"`
+	// Username is the client ID or the name of the user.
+	Username string `json:"username"`
+	// Password is the client secret, the password of a local account or the
+	// API token, not stored for interactive logins.
+	Password string `json:"password,omitempty"`
+	// RefreshToken is the refresh token of interactive logins.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Provider is the name of the identity provider advertised by the server.
+	Provider string `json:"provider,omitempty"`
+	// Issuer is the issuer URL of the OpenID Connect provider of interactive
+	// logins, empty if the server itself issued the tokens.
+	Issuer string `json:"issuer,omitempty"`
+	// ClientID is the client ID of the CLI at the OpenID Connect provider.
+	ClientID string `json:"client_id,omitempty"`
+	// AccessToken is the last access token, reused by later invocations
+	// until it expires. Access tokens of unknown expiry are not stored.
+	AccessToken string `json:"access_token,omitempty"`
+	// ExpiresAt is the expiry of the access token in seconds since the
+	// epoch.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// Pins are the public key pins of the instance, see
+	// secretifyclient.PinnedHTTPClient.
+	Pins []string `json:"pins,omitempty"`
+}
+
+// Token returns the stored access token and refresh token. Access tokens
+// without expiry, as stored by earlier versions, are ignored.
+func (c *Credentials) Token() *secretifyclient.Token {
+	t := &secretifyclient.Token{RefreshToken: c.RefreshToken}
+	if c.ExpiresAt != 0 {
+		t.AccessToken = c.AccessToken
+		t.Expiry = time.Unix(c.ExpiresAt, 0)
+	}
+	return t
+}
+
+// SetToken stores the access token of the token response received now and
+// the refresh token if the server issued or rotated it. Access tokens issued
+// without expiry are not stored, as they could not be renewed before the
+// server rejects them.
+func (c *Credentials) SetToken(resp *secretifyclient.TokenResponse, now time.Time) {
+	c.AccessToken = ""
+	c.ExpiresAt = 0
+	if resp.ExpiresIn > 0 {
+		c.AccessToken = resp.AccessToken
+		c.ExpiresAt = now.Add(time.Duration(resp.ExpiresIn) * time.Second).Unix()
+	}
+	if resp.RefreshToken != "" {
+		c.RefreshToken = resp.RefreshToken
+	}
+}
+
+// AuthMethod returns the authentication method, defaulting to
+// MethodClientCredentials for credentials stored by earlier versions.
+func (c *Credentials) AuthMethod() Method {
+	if c.Method == "" {
+		return MethodClientCredentials
+	}
+	return c.Method
+}
+
+// StoreCredentials stores the credentials of their profile either in keyring
+// if available else as fallback it uses a personalized .netrc file in the state directory.
+func StoreCredentials(c *Credentials) error {
+	c.Profile = ResolveProfile(c.Profile)
+	if err := ValidateProfile(c.Profile); err != nil {
+		return err
+	}
+	err := keyringSet(c)
 	if err == nil {
+		c.Backend = BackendKeyring
 		return nil
 	}
+	if !errors.Is(err, ErrNoKeyring) && !errors.Is(err, keyring.ErrSetDataTooBig) {
+		return fmt.Errorf("could not store credentials in keyring: %v", err)
+	}
 	// Fallback .netrc
-	err = netrcSet(service, username, password)
+	c.Backend = BackendNetrc
+	err = netrcSet(c)
 	if err != nil {
 		return fmt.Errorf("could not create credentials: %v", err)
 
@@ -20,30 +164,76 @@ func StoreCredentials(service, username, password string) error {
 	return nil
 }
 
-// GetCredentials retrieves credentials for the given service.
+// GetCredentials retrieves the stored credentials of the profile.
 // It first tries to retrieve them from the keyring and falls back to the .netrc file.
-func GetCredentials() (string, string, string, error) {
-	service, username, password, err := keyringGet()
+func GetCredentials(profile string) (*Credentials, error) {
+	profile = ResolveProfile(profile)
+	c, err := keyringGet(profile)
 	if err != nil {
 		// Fallback .netrc
-		var err error
-		service, username, password, err = netrcGet()
-		if err != nil {
-			return "", "", "", err
+		var netrcErr error
+		c, netrcErr = netrcGet(profile)
+		if netrcErr != nil {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNoKeyring) {
+				return nil, fmt.Errorf("could not read credentials from keyring: %v", err)
+			}
+			return nil, netrcErr
 		}
 	}
-	return service, username, password, nil
+	return c, nil
 }
 
-// DeleteCredentials removes stored credentials from both the system keyring and the .netrc file.
-func DeleteCredentials() error {
-	err := keyringDelete()
-	if err != nil && err.Error() != "dbus: couldn't determine address of session bus" {
-		return fmt.Errorf("could not delete credentials in keyring: %v", err)
+// Profiles returns the names of all profiles with stored credentials.
+func Profiles() ([]string, error) {
+	keyringProfiles, err := keyringProfiles()
+	if err != nil && !errors.Is(err, ErrNoKeyring) {
+		return nil, fmt.Errorf("could not list profiles in keyring: %v", err)
 	}
-	err = netrcDelete()
+	entries, err := readNetrc()
 	if err != nil {
-		return fmt.Errorf("could not delete credentials in .netrc: %v", err)
+		return nil, fmt.Errorf("could not list profiles in .netrc: %v", err)
 	}
-	return nil
+
+	seen := make(map[string]bool)
+	var profiles []string
+	for _, p := range keyringProfiles {
+		if !seen[p] {
+			seen[p] = true
+			profiles = append(profiles, p)
+		}
+	}
+	for _, e := range entries {
+		if !seen[e.Profile] {
+			seen[e.Profile] = true
+			profiles = append(profiles, e.Profile)
+		}
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// DeleteCredentials removes stored credentials of the profile from both the
+// system keyring and the .netrc file. It returns the backends the
+// credentials were removed from, none if nothing was stored.
+func DeleteCredentials(profile string) ([]Backend, error) {
+	profile = ResolveProfile(profile)
+	var removed []Backend
+
+	err := keyringDelete(profile)
+	switch {
+	case err == nil:
+		removed = append(removed, BackendKeyring)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNoKeyring):
+	default:
+		return removed, fmt.Errorf("could not delete credentials in keyring: %v", err)
+	}
+
+	ok, err := netrcDelete(profile)
+	if err != nil {
+		return removed, fmt.Errorf("could not delete credentials in .netrc: %v", err)
+	}
+	if ok {
+		removed = append(removed, BackendNetrc)
+	}
+	return removed, nil
 }
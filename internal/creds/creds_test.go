@@ -0,0 +1,31 @@
+package creds
+
+import (
+	"testing"
+	"time"
+
+	secretifyclient "secretify-cli/pkg/client"
+)
+
+func TestSetToken(t *testing.T) {
+	now := time.Now()
+	c := &Credentials{RefreshToken: "refresh"}
+
+	c.SetToken(&secretifyclient.TokenResponse{AccessToken: "access", ExpiresIn: 3600}, now)
+	if c.AccessToken != "access" || c.ExpiresAt != now.Add(time.Hour).Unix() || c.RefreshToken != "refresh" {
+		t.Errorf("got %+v", c)
+	}
+	if token := c.Token(); token.AccessToken != "access" || !token.Valid() {
+		t.Errorf("stored token %+v is not reused", token)
+	}
+
+	// Access tokens of unknown expiry are neither stored nor reused
+	c.SetToken(&secretifyclient.TokenResponse{AccessToken: "unknown", RefreshToken: "rotated"}, now)
+	if c.AccessToken != "" || c.ExpiresAt != 0 || c.RefreshToken != "rotated" {
+		t.Errorf("got %+v", c)
+	}
+	c = &Credentials{AccessToken: "legacy"}
+	if token := c.Token(); token.Valid() {
+		t.Errorf("token %+v without expiry stored by earlier versions is reused", token)
+	}
+}
@@ -2,6 +2,10 @@ package creds
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
 
 	keyring "github.com/zalando/go-keyring"
 )
@@ -9,46 +13,129 @@ import (
 // DefaultService is the default service name used for storing credentials.
 const DefaultService string = "secretify"
 
-// DefaultUsername is the default username used for storing credentials.
-const DefaultUsername string = "default"
+// DefaultUsername is the username used for storing the credentials of the
+// default profile, the credentials of other profiles are stored under the
+// name of the profile.
+const DefaultUsername string = DefaultProfile
 
-type data struct {
-	APIURL   string `json:"api_url"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+// profileIndexUsername is the username of the keyring entry listing the
+// profiles stored in the keyring, which cannot be enumerated otherwise.
+const profileIndexUsername = ".profiles"
+
+// ErrNoKeyring is returned if no keyring is available, e.g. because there is
+// no D-Bus session or secret service on Linux.
+var ErrNoKeyring = errors.New("no keyring available")
+
+// ErrNotFound is returned if no credentials are stored for a profile.
+var ErrNotFound = errors.New("no credentials stored")
+
+// keyringError translates errors of the keyring into ErrNotFound and errors
+// wrapping ErrNoKeyring. Other errors, e.g. of a locked keyring, are
+// returned unchanged.
+func keyringError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, keyring.ErrNotFound):
+		return ErrNotFound
+	case isNoKeyring(err):
+		return fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	default:
+		return err
+	}
 }
 
-func keyringSet(service, username, password string) error {
+func keyringSet(c *Credentials) error {
 	// Serialize data to JSON
-	b, err := json.Marshal(data{
-		APIURL:   service,
-		Username: username,
-		Password: password,
-	})
+	b, err := json.Marshal(c)
 	if err != nil {
 		return err
 	}
 	// Store serialized data in the keyring
-	return keyring.Set(DefaultService, DefaultUsername, string(b))
+	if err := keyringError(keyring.Set(DefaultService, c.Profile, string(b))); err != nil {
+		return err
+	}
+	return keyringUpdateIndex(c.Profile, true)
 }
 
-func keyringGet() (string, string, string, error) {
+func keyringGet(profile string) (*Credentials, error) {
 	// Retrieve serialized data from the keyring
-	jsonCreds, err := keyring.Get(DefaultService, DefaultUsername)
+	jsonCreds, err := keyring.Get(DefaultService, profile)
 	if err != nil {
-		return "", "", "", err
+		return nil, keyringError(err)
 	}
 	// Deserialize JSON data into struct
-	var d data
-	err = json.Unmarshal([]byte(jsonCreds), &d)
+	c := Credentials{Profile: profile, Backend: BackendKeyring}
+	err = json.Unmarshal([]byte(jsonCreds), &c)
 	if err != nil {
-		return "", "", "", err
+		return nil, err
 	}
 
-	return d.APIURL, d.Username, d.Password, nil
+	return &c, nil
 }
 
-func keyringDelete() error {
+func keyringDelete(profile string) error {
 	// Delete credentials from keyring
-	return keyring.Delete(DefaultService, DefaultUsername)
+	if err := keyringError(keyring.Delete(DefaultService, profile)); err != nil {
+		return err
+	}
+	return keyringUpdateIndex(profile, false)
+}
+
+// keyringProfiles returns the profiles stored in the keyring. The default
+// profile is included if stored, even if it is missing in the index because
+// it was stored by an earlier version.
+func keyringProfiles() ([]string, error) {
+	var profiles []string
+	index, err := keyring.Get(DefaultService, profileIndexUsername)
+	if err == nil {
+		if err := json.Unmarshal([]byte(index), &profiles); err != nil {
+			return nil, err
+		}
+	} else if err = keyringError(err); !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if !slices.Contains(profiles, DefaultProfile) {
+		_, err := keyring.Get(DefaultService, DefaultProfile)
+		if err == nil {
+			profiles = append(profiles, DefaultProfile)
+			sort.Strings(profiles)
+		} else if err = keyringError(err); !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+// keyringUpdateIndex adds or removes the profile from the profile index.
+func keyringUpdateIndex(profile string, add bool) error {
+	profiles, err := keyringProfiles()
+	if err != nil {
+		return err
+	}
+
+	updated := make([]string, 0, len(profiles)+1)
+	for _, p := range profiles {
+		if p != profile {
+			updated = append(updated, p)
+		}
+	}
+	if add {
+		updated = append(updated, profile)
+	}
+	sort.Strings(updated)
+
+	if len(updated) == 0 {
+		err := keyringError(keyring.Delete(DefaultService, profileIndexUsername))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	b, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+	return keyringError(keyring.Set(DefaultService, profileIndexUsername, string(b)))
 }
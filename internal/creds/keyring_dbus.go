@@ -0,0 +1,30 @@
+//go:build (dragonfly && cgo) || (freebsd && cgo) || linux || netbsd || openbsd
+
+package creds
+
+import (
+	"errors"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// isNoKeyring reports whether the error of the keyring is caused by a
+// missing D-Bus session or Secret Service, as opposed to e.g. a locked
+// keyring or denied access.
+func isNoKeyring(err error) bool {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.DBus.Error.ServiceUnknown",
+			"org.freedesktop.DBus.Error.NameHasNoOwner",
+			"org.freedesktop.DBus.Error.NoServer":
+			return true
+		}
+		return strings.HasPrefix(dbusErr.Name, "org.freedesktop.DBus.Error.Spawn.")
+	}
+
+	// Other errors are caused by the connection to the session bus
+	_, busErr := dbus.SessionBus()
+	return busErr != nil
+}
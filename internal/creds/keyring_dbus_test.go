@@ -0,0 +1,54 @@
+//go:build (dragonfly && cgo) || (freebsd && cgo) || linux || netbsd || openbsd
+
+package creds
+
+import (
+	"errors"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+	keyring "github.com/zalando/go-keyring"
+
+	"secretify-cli/internal/paths"
+)
+
+func TestKeyringError(t *testing.T) {
+	tests := []struct {
+		err       error
+		noKeyring bool
+	}{
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"}, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.Spawn.ChildExited"}, true},
+		{dbus.Error{Name: "org.freedesktop.Secret.Error.IsLocked"}, false},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"}, false},
+	}
+	for _, tt := range tests {
+		err := keyringError(tt.err)
+		if errors.Is(err, ErrNoKeyring) != tt.noKeyring {
+			t.Errorf("keyringError(%v) = %v, want no keyring %v", tt.err, err, tt.noKeyring)
+		}
+	}
+	if err := keyringError(keyring.ErrNotFound); err != ErrNotFound {
+		t.Errorf("keyringError(%v) = %v, want %v", keyring.ErrNotFound, err, ErrNotFound)
+	}
+}
+
+// TestDeleteCredentialsLocked checks that logging out fails if the keyring
+// is locked instead of reporting success.
+func TestDeleteCredentialsLocked(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	keyring.MockInitWithError(dbus.Error{Name: "org.freedesktop.Secret.Error.IsLocked"})
+	if removed, err := DeleteCredentials(DefaultProfile); err == nil {
+		t.Errorf("removed from %v, want error", removed)
+	}
+	if _, err := Profiles(); err == nil {
+		t.Error("listed profiles, want error")
+	}
+
+	// Without Secret Service, the .netrc file is used
+	keyring.MockInitWithError(dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"})
+	removed, err := DeleteCredentials(DefaultProfile)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("removed from %v with error %v, want nothing", removed, err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !((dragonfly && cgo) || (freebsd && cgo) || linux || netbsd || openbsd)
+
+package creds
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// isNoKeyring reports whether the error of the keyring is caused by a
+// missing keychain tool on macOS or an unsupported platform, as opposed to
+// e.g. a locked keychain or denied access.
+func isNoKeyring(err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || strings.HasPrefix(err.Error(), "unsupported platform")
+}
@@ -0,0 +1,55 @@
+package creds
+
+import (
+	"slices"
+	"testing"
+
+	keyring "github.com/zalando/go-keyring"
+
+	"secretify-cli/internal/paths"
+)
+
+// TestKeyringProfilesDefault checks that the default profile is found
+// although earlier versions stored it without profile index.
+func TestKeyringProfilesDefault(t *testing.T) {
+	keyring.MockInit()
+	if err := keyring.Set(DefaultService, DefaultProfile, `{"url":"https://example.com"}`); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := keyringProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profiles, []string{DefaultProfile}) {
+		t.Errorf("got profiles %v, want %v", profiles, []string{DefaultProfile})
+	}
+
+	// Profiles stored later are added to the index
+	if err := keyringSet(&Credentials{Profile: "work", URL: "https://example.org"}); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err = keyringProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profiles, []string{DefaultProfile, "work"}) {
+		t.Errorf("got profiles %v, want %v", profiles, []string{DefaultProfile, "work"})
+	}
+
+	// Deleting the default profile removes it although it is not indexed
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	removed, err := DeleteCredentials(DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []Backend{BackendKeyring}) {
+		t.Errorf("removed from %v, want keyring", removed)
+	}
+	profiles, err = keyringProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profiles, []string{"work"}) {
+		t.Errorf("got profiles %v, want %v", profiles, []string{"work"})
+	}
+}
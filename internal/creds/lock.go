@@ -0,0 +1,36 @@
+package creds
+
+import (
+	"os"
+	"path/filepath"
+
+	"secretify-cli/internal/paths"
+)
+
+// lockCredentials acquires an exclusive lock shared by all invocations of the
+// CLI, so that only one of them renews the tokens at a time. The returned
+// function releases the lock.
+func lockCredentials() (func(), error) {
+	// Create state folder if it doesn't exist
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(stateDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(stateDir, ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -4,79 +4,220 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"secretify-cli/internal/paths"
 )
 
-func netrcSet(service, username, password string) error {
-	// Create .secretify folder if it doesn't exist
-	secretifyFolderPath := os.Getenv("HOME") + "/.secretify"
-	err := os.MkdirAll(secretifyFolderPath, 0700)
+// netrcPath returns the path of the personalized .netrc file in the state
+// directory.
+func netrcPath() (string, error) {
+	dir, err := paths.StateDir()
 	if err != nil {
-		return err
+		return "", err
 	}
+	return filepath.Join(dir, ".netrc"), nil
+}
 
-	// Path to .netrc file
-	netrcPath := secretifyFolderPath + "/.netrc"
-
-	// Construct the new content for the .netrc file
-	newContent := fmt.Sprintf("machine %s login %s password %s\n", service, username, password)
-
-	// TODO: add multiple credentials support instead of just rewriting it always
-	// Write the new content to the .netrc file
-	err = os.WriteFile(netrcPath, []byte(newContent), 0600)
-	if err != nil {
-		return err
+// formatNetrcEntry formats the credentials as a line of the .netrc file. The
+// tokens following the password extend the netrc format.
+func formatNetrcEntry(c *Credentials) string {
+	line := "machine " + c.URL
+	if c.Username != "" {
+		line += " login " + c.Username
 	}
-
-	return nil
+	if c.Password != "" {
+		line += " password " + c.Password
+	}
+	if c.Profile != "" && c.Profile != DefaultProfile {
+		line += " profile " + c.Profile
+	}
+	if c.Method != "" {
+		line += " method " + string(c.Method)
+	}
+	if c.RefreshToken != "" {
+		line += " refresh_token " + c.RefreshToken
+	}
+	if c.Provider != "" {
+		line += " provider " + c.Provider
+	}
+	if c.Issuer != "" {
+		line += " issuer " + c.Issuer
+	}
+	if c.ClientID != "" {
+		line += " client_id " + c.ClientID
+	}
+	if c.AccessToken != "" {
+		line += " access_token " + c.AccessToken
+	}
+	if c.ExpiresAt != 0 {
+		line += " expires_at " + strconv.FormatInt(c.ExpiresAt, 10)
+	}
+	for _, pin := range c.Pins {
+		line += " pin " + pin
+	}
+	return line
 }
 
-func netrcGet() (string, string, string, error) {
-	// Path to .netrc file
-	netrcPath := os.Getenv("HOME") + "/.secretify/.netrc"
+// parseNetrcEntry parses a line of the .netrc file consisting of pairs of
+// tokens and values. Entries without profile belong to the default profile.
+func parseNetrcEntry(line string) (*Credentials, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "machine" {
+		return nil, false
+	}
+	c := &Credentials{Profile: DefaultProfile, Backend: BackendNetrc}
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch fields[i] {
+		case "machine":
+			c.URL = fields[i+1]
+		case "login":
+			c.Username = fields[i+1]
+		case "password":
+			c.Password = fields[i+1]
+		case "profile":
+			c.Profile = fields[i+1]
+		case "method":
+			c.Method = Method(fields[i+1])
+		case "refresh_token":
+			c.RefreshToken = fields[i+1]
+		case "provider":
+			c.Provider = fields[i+1]
+		case "issuer":
+			c.Issuer = fields[i+1]
+		case "client_id":
+			c.ClientID = fields[i+1]
+		case "access_token":
+			c.AccessToken = fields[i+1]
+		case "expires_at":
+			c.ExpiresAt, _ = strconv.ParseInt(fields[i+1], 10, 64)
+		case "pin":
+			c.Pins = append(c.Pins, fields[i+1])
+		}
+	}
+	return c, true
+}
 
+// readNetrc reads all entries of the .netrc file, none if it doesn't exist.
+func readNetrc() ([]*Credentials, error) {
 	// Open .netrc file
-	file, err := os.Open(netrcPath)
+	path, err := netrcPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", "", "", fmt.Errorf("no .netrc file found")
+			return nil, nil
 		}
-		return "", "", "", err
+		return nil, err
 	}
 	defer file.Close()
 
 	// Scan each line of the .netrc file
+	var entries []*Credentials
 	for scanner := bufio.NewScanner(file); scanner.Scan(); {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) >= 4 && fields[0] == "machine" && fields[4] == "password" {
-			return fields[1], fields[3], fields[5], nil // Return machine, login, password
+		if c, ok := parseNetrcEntry(scanner.Text()); ok {
+			entries = append(entries, c)
 		}
 	}
-
-	// If no matching entry is found
-	return "", "", "", fmt.Errorf("no credentials found in .netrc file")
+	return entries, nil
 }
 
-func netrcDelete() error {
-	// Path to .netrc file
-	netrcPath := os.Getenv("HOME") + "/.secretify/.netrc"
-
-	// Check if .netrc file exists
-	_, err := os.Stat(netrcPath)
-	if os.IsNotExist(err) {
-		// If .netrc file doesn't exist, return nil (no error)
-		return nil
-	} else if err != nil {
-		// If there's an error other than file not found, return the error
+// writeNetrc replaces the .netrc file with the entries, or deletes it if
+// there are none.
+func writeNetrc(entries []*Credentials) error {
+	path, err := netrcPath()
+	if err != nil {
 		return err
 	}
+	if len(entries) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not delete .netrc file: %v", err)
+		}
+		return nil
+	}
 
-	// Delete the .netrc file
-	err = os.Remove(netrcPath)
+	// Create state folder if it doesn't exist
+	err = os.MkdirAll(filepath.Dir(path), 0700)
 	if err != nil {
-		return fmt.Errorf("could not delete .netrc file: %v", err)
+		return err
 	}
 
+	var b strings.Builder
+	for _, c := range entries {
+		b.WriteString(formatNetrcEntry(c) + "\n")
+	}
+
+	// Write the new content to a temporary file replacing the .netrc file,
+	// so that concurrent invocations never read a partially written file
+	tmpPath := path + ".tmp"
+	err = os.WriteFile(tmpPath, []byte(b.String()), 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
 	return nil
 }
+
+func netrcSet(c *Credentials) error {
+	entries, err := readNetrc()
+	if err != nil {
+		return err
+	}
+
+	// Replace the entry of the profile, keeping the other profiles
+	updated := []*Credentials{c}
+	for _, e := range entries {
+		if e.Profile != c.Profile {
+			updated = append(updated, e)
+		}
+	}
+	return writeNetrc(updated)
+}
+
+func netrcGet(profile string) (*Credentials, error) {
+	entries, err := readNetrc()
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		return nil, fmt.Errorf("no .netrc file found")
+	}
+
+	for _, c := range entries {
+		if c.Profile == profile {
+			return c, nil
+		}
+	}
+
+	// If no matching entry is found
+	return nil, fmt.Errorf("no credentials found in .netrc file for profile %s", profile)
+}
+
+// netrcDelete removes the entry of the profile and reports whether there was one.
+func netrcDelete(profile string) (bool, error) {
+	entries, err := readNetrc()
+	if err != nil {
+		return false, err
+	}
+
+	// Remove only the entry of the profile
+	var remaining []*Credentials
+	for _, e := range entries {
+		if e.Profile != profile {
+			remaining = append(remaining, e)
+		}
+	}
+	if len(remaining) == len(entries) {
+		return false, nil
+	}
+	return true, writeNetrc(remaining)
+}
@@ -0,0 +1,173 @@
+package creds
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"secretify-cli/internal"
+	secretifyclient "secretify-cli/pkg/client"
+)
+
+// HTTPClient returns the HTTP client for requests with the credentials. If
+// public keys are pinned, connections to the instance are only accepted if
+// it presents one of them.
+func HTTPClient(c *Credentials) (*http.Client, error) {
+	if len(c.Pins) == 0 {
+		return secretifyclient.DefaultHTTPClient, nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == secretifyclient.UnixScheme {
+		return secretifyclient.DefaultHTTPClient, nil
+	}
+	return secretifyclient.PinnedHTTPClient(secretifyclient.DefaultHTTPClient, u.Hostname(), c.Pins)
+}
+
+// newClient creates a client for the instance of the credentials, without
+// authentication.
+func newClient(c *Credentials) (*secretifyclient.HTTP, error) {
+	client := secretifyclient.NewHTTP(fmt.Sprintf(internal.APIURL, c.URL), "")
+	httpClient, err := HTTPClient(c)
+	if err != nil {
+		return nil, err
+	}
+	client.Client = httpClient
+	return client, nil
+}
+
+// Authenticator returns the authenticator for the stored credentials.
+func Authenticator(c *Credentials) (secretifyclient.Authenticator, error) {
+	client, err := newClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.AuthMethod() {
+	case MethodClientCredentials:
+		return &secretifyclient.ClientCredentials{Client: client, ClientID: c.Username, ClientSecret: c.Password}, nil
+	case MethodPassword:
+		return &secretifyclient.LocalAccount{Client: client, Username: c.Username, Password: c.Password}, nil
+	case MethodToken:
+		return secretifyclient.StaticToken(c.Password), nil
+	case MethodDevice, MethodBrowser:
+		if c.RefreshToken == "" {
+			return nil, fmt.Errorf("no refresh token stored, please login again")
+		}
+		provider := secretifyclient.Provider{Issuer: c.Issuer, ClientID: c.ClientID}
+		oauth, err := provider.OAuth(client)
+		if err != nil {
+			return nil, err
+		}
+		return &secretifyclient.RefreshToken{OAuth: oauth, RefreshToken: c.RefreshToken}, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %q", c.Method)
+	}
+}
+
+// Revoke revokes the refresh token, or else the access token, of the
+// credentials at the server or identity provider that issued it. It reports
+// false without an error if there is no token to revoke or no revocation
+// endpoint. Static API tokens are never revoked.
+func Revoke(c *Credentials) (bool, error) {
+	token, hint := c.RefreshToken, secretifyclient.TokenTypeHintRefreshToken
+	if token == "" {
+		token, hint = c.AccessToken, secretifyclient.TokenTypeHintAccessToken
+	}
+	if token == "" || c.AuthMethod() == MethodToken {
+		return false, nil
+	}
+
+	client, err := newClient(c)
+	if err != nil {
+		return false, err
+	}
+	oauth := client.OAuth()
+	if c.AuthMethod() == MethodDevice || c.AuthMethod() == MethodBrowser {
+		provider := secretifyclient.Provider{Issuer: c.Issuer, ClientID: c.ClientID}
+		oauth, err = provider.OAuth(client)
+		if err != nil {
+			return false, err
+		}
+	}
+	return oauth.Revoke(token, hint)
+}
+
+// TokenSource returns a token source for the stored credentials. It reuses
+// the stored access token until it expires or the server rejects it and then
+// renews it, storing the new access token and a rotated refresh token.
+// Renewals of concurrent invocations are serialized with a lock.
+func TokenSource(c *Credentials) secretifyclient.TokenSource {
+	if c.AuthMethod() == MethodToken {
+		return secretifyclient.ReuseTokenSource(&secretifyclient.Token{AccessToken: c.Password}, secretifyclient.StaticToken(c.Password))
+	}
+	return secretifyclient.ReuseTokenSource(c.Token(), &storingAuthenticator{creds: c})
+}
+
+// storingAuthenticator authenticates with the stored credentials and stores
+// the obtained tokens.
+type storingAuthenticator struct {
+	creds *Credentials
+}
+
+// Authenticate implements secretifyclient.Authenticator.
+func (a *storingAuthenticator) Authenticate() (*secretifyclient.TokenResponse, error) {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("could not lock credentials: %v", err)
+	}
+	defer unlock()
+
+	// Another invocation may have renewed the tokens while waiting for the
+	// lock, in which case the refresh token used so far may be invalidated
+	if stored, err := GetCredentials(a.creds.Profile); err == nil && stored.URL == a.creds.URL && stored.AuthMethod() == a.creds.AuthMethod() {
+		a.creds = stored
+		if token := stored.Token(); token.Valid() {
+			return &secretifyclient.TokenResponse{AccessToken: token.AccessToken, ExpiresIn: int(time.Until(token.Expiry).Seconds())}, nil
+		}
+	}
+
+	authenticator, err := Authenticator(a.creds)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := authenticator.Authenticate()
+	if err != nil {
+		if a.creds.RefreshToken != "" {
+			return nil, fmt.Errorf("could not refresh token, please login again: %v", err)
+		}
+		return nil, err
+	}
+
+	a.creds.SetToken(resp, time.Now())
+	if err := StoreCredentials(a.creds); err != nil {
+		return nil, fmt.Errorf("could not store tokens: %v", err)
+	}
+	return resp, nil
+}
+
+// Invalidate implements secretifyclient.Invalidator. It removes the access
+// token rejected by the server from the stored credentials, so that neither
+// this nor later invocations reuse it.
+func (a *storingAuthenticator) Invalidate(accessToken string) {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	if a.creds.AccessToken == accessToken {
+		a.creds.AccessToken = ""
+		a.creds.ExpiresAt = 0
+	}
+	stored, err := GetCredentials(a.creds.Profile)
+	if err != nil || stored.AccessToken != accessToken {
+		return
+	}
+	stored.AccessToken = ""
+	stored.ExpiresAt = 0
+	StoreCredentials(stored)
+}
@@ -0,0 +1,56 @@
+package creds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	keyring "github.com/zalando/go-keyring"
+
+	"secretify-cli/internal/paths"
+	secretifyclient "secretify-cli/pkg/client"
+)
+
+// TestTokenSourceStoresRotatedRefreshToken checks that renewing the access
+// token of an interactive login stores the new access token and the refresh
+// token rotated by the server.
+func TestTokenSourceStoresRotatedRefreshToken(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv(paths.HomeEnv, t.TempDir())
+
+	var refreshTokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/auth/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		refreshTokens = append(refreshTokens, r.PostForm.Get("refresh_token"))
+		json.NewEncoder(w).Encode(secretifyclient.TokenResponse{AccessToken: "access", RefreshToken: "rotated", ExpiresIn: 3600})
+	}))
+	defer srv.Close()
+
+	c := &Credentials{Profile: "test", URL: srv.URL, Method: MethodDevice, RefreshToken: "refresh"}
+	if err := StoreCredentials(c); err != nil {
+		t.Fatal(err)
+	}
+	token, err := TokenSource(c).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access" {
+		t.Errorf("got access token %q, want %q", token.AccessToken, "access")
+	}
+	if len(refreshTokens) != 1 || refreshTokens[0] != "refresh" {
+		t.Errorf("refreshed with %q, want %q", refreshTokens, "refresh")
+	}
+
+	stored, err := GetCredentials("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RefreshToken != "rotated" || stored.AccessToken != "access" || stored.ExpiresAt == 0 {
+		t.Errorf("stored %+v, want the rotated refresh token and the access token", stored)
+	}
+}
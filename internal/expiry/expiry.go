@@ -0,0 +1,204 @@
+package expiry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits restricts the accepted expiry durations and number of views.
+// Zero values mean no restriction.
+type Limits struct {
+	MinExpiry time.Duration
+	MaxExpiry time.Duration
+	MinViews  int
+	MaxViews  int
+}
+
+var unitPattern = regexp.MustCompile(`(\d+)([wd])`)
+
+// maxDays is the largest number of days a time.Duration can hold.
+const maxDays = int64(math.MaxInt64 / (24 * time.Hour))
+
+// errTooLong reports durations exceeding the range of time.Duration.
+var errTooLong = errors.New("duration too long")
+
+var dateLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Parse resolves the given expiry relative to now into an absolute point in
+// time. Supported are durations ("30m", "24h", "7d", "1w2d"), RFC3339
+// timestamps, local dates ("2024-05-01", "2024-05-01 09:00") and natural
+// forms ("tomorrow 09:00", "today 17:30", "friday", "monday 08:00").
+func Parse(input string, now time.Time) (time.Time, error) {
+	s := strings.ToLower(strings.TrimSpace(input))
+	if s == "" {
+		return time.Time{}, fmt.Errorf("no expiry provided")
+	}
+
+	d, err := ParseDuration(s)
+	if err == nil {
+		return now.Add(d), nil
+	}
+	if errors.Is(err, errTooLong) {
+		return time.Time{}, fmt.Errorf("expiry %q is too far in the future", input)
+	}
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(s)); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(s), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, ok := parseNatural(s, now); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q. Use e.g. 30m, 7d, 2024-05-01T09:00:00Z or \"tomorrow 09:00\"", input)
+}
+
+// ParseDuration parses Go durations extended by the units d (day) and w (week).
+func ParseDuration(s string) (time.Duration, error) {
+	// Days saturate above maxDays, the digits can only fail to parse if
+	// they are out of range
+	var days int64
+	rest := unitPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := unitPattern.FindStringSubmatch(m)
+		n, err := strconv.ParseInt(sub[1], 10, 64)
+		if err != nil || n > maxDays {
+			n = maxDays + 1
+		}
+		if sub[2] == "w" {
+			n *= 7
+		}
+		days = min(days+n, maxDays+1)
+		return ""
+	})
+	if days > maxDays {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, errTooLong)
+	}
+
+	var d time.Duration
+	if rest != "" {
+		var err error
+		d, err = time.ParseDuration(rest)
+		if err != nil {
+			return 0, err
+		}
+	} else if days == 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	daysDuration := time.Duration(days) * 24 * time.Hour
+	if d > math.MaxInt64-daysDuration {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, errTooLong)
+	}
+	return d + daysDuration, nil
+}
+
+// parseNatural parses "<day> [HH:MM]" where day is today, tomorrow or a weekday.
+func parseNatural(s string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, false
+	}
+
+	var offset int
+	switch day := fields[0]; day {
+	case "today":
+	case "tomorrow":
+		offset = 1
+	default:
+		weekday, ok := weekdays[day]
+		if !ok {
+			return time.Time{}, false
+		}
+		offset = int(weekday - now.Weekday())
+		if offset <= 0 {
+			offset += 7
+		}
+	}
+
+	// Without a time the expiry keeps the current time of day
+	hour, minute := now.Hour(), now.Minute()
+	if len(fields) == 2 {
+		t, err := time.Parse("15:04", fields[1])
+		if err != nil {
+			return time.Time{}, false
+		}
+		hour, minute = t.Hour(), t.Minute()
+	}
+
+	y, m, d := now.Date()
+	return time.Date(y, m, d+offset, hour, minute, 0, 0, now.Location()), true
+}
+
+// Resolve parses the expiry relative to now and checks it and the number of
+// views against the limits. It returns the absolute expiry and the duration
+// until then, rounded to full minutes.
+func Resolve(input string, views int, limits Limits, now time.Time) (time.Time, time.Duration, error) {
+	t, err := Parse(input, now)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	d := t.Sub(now)
+
+	if d <= 0 {
+		return time.Time{}, 0, fmt.Errorf("expiry %s is in the past", t.Format(time.RFC3339))
+	}
+	if d < time.Minute {
+		return time.Time{}, 0, fmt.Errorf("expiry %s is less than a minute away", t.Format(time.RFC3339))
+	}
+	d = d.Round(time.Minute)
+	if limits.MinExpiry > 0 && d < limits.MinExpiry {
+		return time.Time{}, 0, fmt.Errorf("expiry %s is shorter than the minimum of %s", Format(d), Format(limits.MinExpiry))
+	}
+	if limits.MaxExpiry > 0 && d > limits.MaxExpiry {
+		return time.Time{}, 0, fmt.Errorf("expiry %s exceeds the maximum of %s", Format(d), Format(limits.MaxExpiry))
+	}
+
+	if views < 1 {
+		return time.Time{}, 0, fmt.Errorf("views must be at least 1")
+	}
+	if limits.MinViews > 0 && views < limits.MinViews {
+		return time.Time{}, 0, fmt.Errorf("views must be at least %d", limits.MinViews)
+	}
+	if limits.MaxViews > 0 && views > limits.MaxViews {
+		return time.Time{}, 0, fmt.Errorf("views must not exceed %d", limits.MaxViews)
+	}
+
+	return now.Add(d), d, nil
+}
+
+// Format formats a duration compactly in hours and minutes, e.g. 168h or
+// 1h30m, as understood by the server.
+func Format(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d / time.Hour)
+	minutes := int((d % time.Hour) / time.Minute)
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+}
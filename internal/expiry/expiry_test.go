@@ -0,0 +1,159 @@
+package expiry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday.
+var now = time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		// Relative
+		{"30m", now.Add(30 * time.Minute)},
+		{"24h", now.Add(24 * time.Hour)},
+		{"7d", now.AddDate(0, 0, 7)},
+		{"1w2d", now.AddDate(0, 0, 9)},
+		{"1d12h", now.Add(36 * time.Hour)},
+		{" 2W ", now.AddDate(0, 0, 14)},
+		// Absolute
+		{"2024-05-03T09:00:00Z", time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC)},
+		{"2024-05-03t09:00:00+02:00", time.Date(2024, 5, 3, 7, 0, 0, 0, time.UTC)},
+		{"2024-05-03", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-03 09:15", time.Date(2024, 5, 3, 9, 15, 0, 0, time.UTC)},
+		{"2024-05-03T09:15", time.Date(2024, 5, 3, 9, 15, 0, 0, time.UTC)},
+		// Natural
+		{"today 17:30", time.Date(2024, 5, 1, 17, 30, 0, 0, time.UTC)},
+		{"tomorrow", time.Date(2024, 5, 2, 12, 30, 0, 0, time.UTC)},
+		{"Tomorrow 09:00", time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+		{"friday", time.Date(2024, 5, 3, 12, 30, 0, 0, time.UTC)},
+		{"wednesday 08:00", time.Date(2024, 5, 8, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"soon",
+		"0d",
+		"5x",
+		"99999999999999999999d",
+		"2024-13-01",
+		"tomorrow 25:00",
+		"friday 9am",
+		"next friday 09:00",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got, err := Parse(in, now); err == nil {
+				t.Errorf("Parse(%q) = %s, want error", in, got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	limits := Limits{MinExpiry: 5 * time.Minute, MaxExpiry: 30 * 24 * time.Hour, MinViews: 1, MaxViews: 10}
+	tests := []struct {
+		name    string
+		in      string
+		views   int
+		limits  Limits
+		want    time.Duration
+		wantErr string
+	}{
+		{"relative", "24h", 1, limits, 24 * time.Hour, ""},
+		{"absolute", "2024-05-02T12:30:00Z", 1, limits, 24 * time.Hour, ""},
+		{"rounded", "2024-05-01T13:00:29Z", 1, limits, 30 * time.Minute, ""},
+		{"one minute", "1m", 1, Limits{}, time.Minute, ""},
+		{"far future", "106751d", 1, Limits{}, 106751 * 24 * time.Hour, ""},
+		{"past", "2024-04-30", 1, limits, 0, "in the past"},
+		{"now", "2024-05-01T12:30:00Z", 1, limits, 0, "in the past"},
+		{"sub-minute", "20s", 1, Limits{}, 0, "less than a minute"},
+		{"sub-minute absolute", "2024-05-01T12:30:59Z", 1, Limits{}, 0, "less than a minute"},
+		{"overflowing days", "106752d", 1, Limits{}, 0, "too far in the future"},
+		{"overflowing weeks", "15251w", 1, Limits{}, 0, "too far in the future"},
+		{"overflowing sum", "106751d24h", 1, Limits{}, 0, "too far in the future"},
+		{"out of range", "99999999999999999999d", 1, Limits{}, 0, "too far in the future"},
+		{"below minimum", "1m", 1, limits, 0, "shorter than the minimum"},
+		{"above maximum", "31d", 1, limits, 0, "exceeds the maximum"},
+		{"no views", "24h", 0, limits, 0, "at least 1"},
+		{"too many views", "24h", 11, limits, 0, "must not exceed"},
+		{"invalid", "later", 1, limits, 0, "invalid expiry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, d, err := Resolve(tt.in, tt.views, tt.limits, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Resolve(%q) = %s, %v, want error %q", tt.in, d, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != tt.want || !at.Equal(now.Add(tt.want)) {
+				t.Errorf("Resolve(%q) = %s %s, want %s", tt.in, at, d, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"2d", 48 * time.Hour},
+		{"1w", 168 * time.Hour},
+		{"1d1h", 25 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{"", "d", "0w", "1y", "99999999999999999999d"} {
+		if got, err := ParseDuration(in); err == nil {
+			t.Errorf("ParseDuration(%q) = %s, want error", in, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{45 * time.Minute, "45m"},
+		{24 * time.Hour, "24h"},
+		{90 * time.Minute, "1h30m"},
+		{89*time.Minute + 45*time.Second, "1h30m"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("Format(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package paths
+
+func isCrossDevice(err error) bool {
+	return false
+}
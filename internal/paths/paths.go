@@ -0,0 +1,132 @@
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HomeEnv is the environment variable of the directory all files are kept in
+// instead of the XDG base directories:
+//
+//	config  $XDG_CONFIG_HOME/secretify or ~/.config/secretify
+//	state   $XDG_STATE_HOME/secretify or ~/.local/state/secretify
+//	cache   $XDG_CACHE_HOME/secretify or ~/.cache/secretify
+const HomeEnv = "SECRETIFY_HOME"
+
+// appName is the name of the subdirectory in the XDG base directories.
+const appName = "secretify"
+
+// ConfigDir returns the directory of the configuration and the identity.
+func ConfigDir() (string, error) {
+	return resolve("XDG_CONFIG_HOME", ".config")
+}
+
+// StateDir returns the directory of the stored credentials.
+func StateDir() (string, error) {
+	return resolve("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// CacheDir returns the directory of files that can be recreated at any time.
+func CacheDir() (string, error) {
+	dir, err := resolve("XDG_CACHE_HOME", ".cache")
+	if err != nil {
+		return "", err
+	}
+	if os.Getenv(HomeEnv) != "" {
+		return filepath.Join(dir, "cache"), nil
+	}
+	return dir, nil
+}
+
+// resolve returns the directory below the XDG base directory of the
+// environment variable, falling back to the home directory.
+func resolve(env, fallback string) (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory, set %s: %v", HomeEnv, err)
+	}
+	return filepath.Join(home, fallback, appName), nil
+}
+
+// legacyDir returns the directory used by previous versions, ~/.secretify.
+func legacyDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".secretify"), nil
+}
+
+// Migrate moves the files of previous versions from ~/.secretify to the
+// current directories. Files already present in the current directories are
+// not overwritten. It returns the paths of the moved files.
+func Migrate() ([]string, error) {
+	legacy, err := legacyDir()
+	if err != nil {
+		return nil, nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return nil, nil
+	}
+	configDir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	stateDir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []string
+	for name, dir := range map[string]string{".netrc": stateDir, "id": configDir} {
+		src := filepath.Join(legacy, name)
+		dst := filepath.Join(dir, name)
+		if src == dst {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return moved, err
+		}
+		if err := move(src, dst); err != nil {
+			return moved, fmt.Errorf("could not move %s to %s: %v", src, dst, err)
+		}
+		moved = append(moved, dst)
+	}
+
+	// Remove the lock file and the directory if nothing else is left
+	if len(moved) > 0 {
+		os.Remove(filepath.Join(legacy, ".lock"))
+		os.Remove(legacy)
+	}
+	return moved, nil
+}
+
+// move renames the file, copying it if the directories are on different
+// file systems.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, b, 0600); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
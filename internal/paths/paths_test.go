@@ -0,0 +1,226 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// setHome points the home directory at a temporary directory and clears the
+// environment variables overriding the directories.
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(HomeEnv, "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	return home
+}
+
+func TestDirs(t *testing.T) {
+	home := setHome(t)
+	xdg := t.TempDir()
+	tests := []struct {
+		name                 string
+		env                  map[string]string
+		config, state, cache string
+	}{
+		{"home", nil, filepath.Join(home, ".config", "secretify"), filepath.Join(home, ".local", "state", "secretify"), filepath.Join(home, ".cache", "secretify")},
+		{"xdg", map[string]string{"XDG_CONFIG_HOME": filepath.Join(xdg, "config"), "XDG_STATE_HOME": filepath.Join(xdg, "state"), "XDG_CACHE_HOME": filepath.Join(xdg, "cache")},
+			filepath.Join(xdg, "config", "secretify"), filepath.Join(xdg, "state", "secretify"), filepath.Join(xdg, "cache", "secretify")},
+		{"relative xdg ignored", map[string]string{"XDG_CONFIG_HOME": "config"}, filepath.Join(home, ".config", "secretify"), filepath.Join(home, ".local", "state", "secretify"), filepath.Join(home, ".cache", "secretify")},
+		{"secretify home", map[string]string{HomeEnv: xdg, "XDG_CONFIG_HOME": filepath.Join(home, "config")}, xdg, xdg, filepath.Join(xdg, "cache")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			for _, d := range []struct {
+				name string
+				dir  func() (string, error)
+				want string
+			}{
+				{"config", ConfigDir, tt.config},
+				{"state", StateDir, tt.state},
+				{"cache", CacheDir, tt.cache},
+			} {
+				got, err := d.dir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != d.want {
+					t.Errorf("got %s directory %s, want %s", d.name, got, d.want)
+				}
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name string
+		// env returns the environment and the expected config and state
+		// directories.
+		env        func(home, tmp string) (env map[string]string, config, state string)
+		existing   bool
+		keepLegacy bool
+		wantNetrc  string
+	}{
+		{
+			name: "home",
+			env: func(home, tmp string) (map[string]string, string, string) {
+				return nil, filepath.Join(home, ".config", "secretify"), filepath.Join(home, ".local", "state", "secretify")
+			},
+			wantNetrc: "legacy netrc",
+		},
+		{
+			name: "xdg",
+			env: func(home, tmp string) (map[string]string, string, string) {
+				return map[string]string{"XDG_CONFIG_HOME": filepath.Join(tmp, "config"), "XDG_STATE_HOME": filepath.Join(tmp, "state")},
+					filepath.Join(tmp, "config", "secretify"), filepath.Join(tmp, "state", "secretify")
+			},
+			wantNetrc: "legacy netrc",
+		},
+		{
+			name: "secretify home",
+			env: func(home, tmp string) (map[string]string, string, string) {
+				return map[string]string{HomeEnv: tmp}, tmp, tmp
+			},
+			wantNetrc: "legacy netrc",
+		},
+		{
+			name: "existing destination kept",
+			env: func(home, tmp string) (map[string]string, string, string) {
+				return map[string]string{"XDG_CONFIG_HOME": filepath.Join(tmp, "config"), "XDG_STATE_HOME": filepath.Join(tmp, "state")},
+					filepath.Join(tmp, "config", "secretify"), filepath.Join(tmp, "state", "secretify")
+			},
+			existing:   true,
+			keepLegacy: true,
+			wantNetrc:  "current netrc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setHome(t)
+			env, configDir, stateDir := tt.env(home, t.TempDir())
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			legacy := filepath.Join(home, ".secretify")
+			writeFile(t, filepath.Join(legacy, ".netrc"), "legacy netrc")
+			writeFile(t, filepath.Join(legacy, "id"), "legacy id")
+			writeFile(t, filepath.Join(legacy, ".lock"), "")
+			if tt.existing {
+				writeFile(t, filepath.Join(stateDir, ".netrc"), "current netrc")
+			}
+
+			moved, err := Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{filepath.Join(configDir, "id")}
+			if !tt.existing {
+				want = append(want, filepath.Join(stateDir, ".netrc"))
+			}
+			slices.Sort(moved)
+			slices.Sort(want)
+			if !slices.Equal(moved, want) {
+				t.Errorf("moved %v, want %v", moved, want)
+			}
+			if got := readFile(t, filepath.Join(stateDir, ".netrc")); got != tt.wantNetrc {
+				t.Errorf("got .netrc %q, want %q", got, tt.wantNetrc)
+			}
+			if got := readFile(t, filepath.Join(configDir, "id")); got != "legacy id" {
+				t.Errorf("got id %q, want %q", got, "legacy id")
+			}
+
+			// The legacy directory is removed only if nothing is left but
+			// the lock file
+			if exists(legacy) != tt.keepLegacy {
+				t.Errorf("legacy directory exists: %v, want %v", exists(legacy), tt.keepLegacy)
+			}
+			if tt.keepLegacy && readFile(t, filepath.Join(legacy, ".netrc")) != "legacy netrc" {
+				t.Error("file not moved was changed")
+			}
+
+			// Migrating again moves nothing
+			moved, err = Migrate()
+			if err != nil || len(moved) != 0 {
+				t.Errorf("second migration moved %v, error %v", moved, err)
+			}
+		})
+	}
+}
+
+// TestMigrateKeepsOtherFiles checks that the legacy directory is kept if it
+// contains files not known to the migration.
+func TestMigrateKeepsOtherFiles(t *testing.T) {
+	home := setHome(t)
+	legacy := filepath.Join(home, ".secretify")
+	writeFile(t, filepath.Join(legacy, ".netrc"), "legacy netrc")
+	writeFile(t, filepath.Join(legacy, "notes"), "unrelated")
+
+	moved, err := Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 {
+		t.Errorf("moved %v, want the .netrc file", moved)
+	}
+	if readFile(t, filepath.Join(legacy, "notes")) != "unrelated" {
+		t.Error("unrelated file was changed")
+	}
+}
+
+func TestMigrateWithoutLegacyDir(t *testing.T) {
+	home := setHome(t)
+	moved, err := Migrate()
+	if err != nil || len(moved) != 0 {
+		t.Errorf("moved %v, error %v", moved, err)
+	}
+	if exists(filepath.Join(home, ".config")) || exists(filepath.Join(home, ".local")) {
+		t.Error("directories created without legacy files")
+	}
+}
+
+// TestMoveMissingSource checks that errors other than a rename across file
+// systems are returned without falling back to copying.
+func TestMoveMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "dst")
+	if err := move(filepath.Join(dir, "missing"), dst); err == nil {
+		t.Fatal("expected error")
+	}
+	if exists(dst) {
+		t.Error("destination created")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package prompt
+
+import "errors"
+
+func disableEcho(fd int) (func(), error) {
+	return nil, errors.New("hiding input is not supported on this platform")
+}
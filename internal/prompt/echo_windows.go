@@ -0,0 +1,19 @@
+//go:build windows
+
+package prompt
+
+import "golang.org/x/sys/windows"
+
+func disableEcho(fd int) (func(), error) {
+	var mode uint32
+	if err := windows.GetConsoleMode(windows.Handle(fd), &mode); err != nil {
+		return nil, err
+	}
+	newMode := mode&^windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT
+	if err := windows.SetConsoleMode(windows.Handle(fd), newMode); err != nil {
+		return nil, err
+	}
+	return func() {
+		windows.SetConsoleMode(windows.Handle(fd), mode)
+	}, nil
+}
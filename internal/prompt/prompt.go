@@ -0,0 +1,153 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"secretify-cli/pkg/crypto"
+
+	"golang.org/x/term"
+)
+
+// MultilineTerminator ends a multi-line input when entered on its own line.
+const MultilineTerminator = "."
+
+// stdin is shared between all prompts so buffered input is not lost between calls.
+var stdin = bufio.NewReader(os.Stdin)
+
+// IsInteractive reports whether stdin is attached to a terminal.
+func IsInteractive() bool {
+	return term.IsTerminal(int(syscall.Stdin))
+}
+
+// Line prompts for a single line of input.
+func Line(label string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	return readLine()
+}
+
+// Multiline prompts for input spanning multiple lines. Input ends with a line
+// only containing MultilineTerminator or at EOF.
+func Multiline(label string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s (end with a line containing only %q):\n", label, MultilineTerminator)
+
+	var lines []string
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if line == MultilineTerminator {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Secret prompts for a value without echoing it to the terminal. The value
+// should be wiped with crypto.Wipe after use.
+func Secret(label string) ([]byte, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+
+	// Read through the shared reader with echo disabled, so that input
+	// buffered by previous prompts is read in order
+	if IsInteractive() {
+		restore, err := disableEcho(int(syscall.Stdin))
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %v", err)
+		}
+		defer restore()
+	}
+	value, err := readSecretLine()
+	fmt.Fprint(os.Stderr, "\n")
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+	return value, nil
+}
+
+// ConfirmedSecret prompts for a value twice without echoing it and fails if
+// both entries differ. The value should be wiped with crypto.Wipe after use.
+func ConfirmedSecret(label string) ([]byte, error) {
+	value, err := Secret(label)
+	if err != nil {
+		return nil, err
+	}
+	confirmation, err := Secret("Confirm " + label)
+	if err != nil {
+		crypto.Wipe(value)
+		return nil, err
+	}
+	defer crypto.Wipe(confirmation)
+	if subtle.ConstantTimeCompare(value, confirmation) != 1 {
+		crypto.Wipe(value)
+		return nil, fmt.Errorf("entries for %s do not match", label)
+	}
+	return value, nil
+}
+
+// Confirm asks a yes/no question and returns def if the answer is empty.
+func Confirm(label string, def bool) (bool, error) {
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", label, hint)
+
+	answer, err := readLine()
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readSecretLine reads a line into a new slice, wiping the input from the
+// buffer of the shared reader and from slices outgrown on long lines.
+func readSecretLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := stdin.ReadSlice('\n')
+		if len(line)+len(chunk) > cap(line) {
+			grown := make([]byte, len(line), 2*cap(line)+len(chunk))
+			copy(grown, line)
+			crypto.Wipe(line)
+			line = grown
+		}
+		line = append(line, chunk...)
+		crypto.Wipe(chunk)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			crypto.Wipe(line)
+			return nil, err
+		}
+		break
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
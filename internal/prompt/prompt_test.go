@@ -0,0 +1,38 @@
+package prompt
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// TestReadOrder checks that plain and hidden prompts consume the input in
+// order from the shared reader, including lines longer than its buffer.
+func TestReadOrder(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	stdin = bufio.NewReader(strings.NewReader("alice\r\n" + long + "\nline 1\nline 2\n.\nsecret\nsecret\nlast"))
+
+	if v, err := Line("Username"); err != nil || v != "alice" {
+		t.Fatalf("Line = %q, %v", v, err)
+	}
+	if v, err := Secret("Key"); err != nil || string(v) != long {
+		t.Fatalf("Secret = %d bytes, %v, want %d bytes", len(v), err, len(long))
+	}
+	if v, err := Multiline("Notes"); err != nil || v != "line 1\nline 2" {
+		t.Fatalf("Multiline = %q, %v", v, err)
+	}
+	if v, err := ConfirmedSecret("Password"); err != nil || string(v) != "secret" {
+		t.Fatalf("ConfirmedSecret = %q, %v", v, err)
+	}
+	if v, err := Secret("Token"); err != nil || string(v) != "last" {
+		t.Fatalf("Secret at EOF = %q, %v", v, err)
+	}
+	if _, err := Secret("Token"); err == nil {
+		t.Fatal("Secret after EOF succeeded")
+	}
+
+	stdin = bufio.NewReader(strings.NewReader("secret\nsecreT\n"))
+	if _, err := ConfirmedSecret("Password"); err == nil || !strings.Contains(err.Error(), "do not match") {
+		t.Fatalf("error %v, want mismatch", err)
+	}
+}
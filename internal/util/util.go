@@ -1,9 +1,13 @@
 package util
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 // ExtractDataSets extracts key-value pairs from an array of strings in the format "key=value".
@@ -51,3 +55,119 @@ func B64DecodeCredentials(b64String string) (string, string, error) {
 
 	return "", "", fmt.Errorf("coult not decode credentials")
 }
+
+// WriteJSONObject writes the map as JSON object with sorted keys followed by a
+// newline, equal to the output of json.Marshal. In contrast to json.Marshal,
+// the values are never converted to strings and the intermediate buffer is
+// wiped after writing, so secrets can be wiped by the caller.
+func WriteJSONObject(w io.Writer, m map[string][]byte) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Allocate the buffer at its final size, so that it never grows and
+	// leaves copies of the values behind
+	var size byteCounter
+	writeJSONObject(&size, keys, m)
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	defer func() {
+		b := buf.Bytes()
+		clear(b[:cap(b)])
+	}()
+
+	writeJSONObject(buf, keys, m)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// jsonWriter is implemented by bytes.Buffer and byteCounter.
+type jsonWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	WriteRune(r rune) (int, error)
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter int
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+func (c *byteCounter) WriteByte(byte) error {
+	*c++
+	return nil
+}
+
+func (c *byteCounter) WriteString(s string) (int, error) {
+	*c += byteCounter(len(s))
+	return len(s), nil
+}
+
+func (c *byteCounter) WriteRune(r rune) (int, error) {
+	n := utf8.RuneLen(r)
+	*c += byteCounter(n)
+	return n, nil
+}
+
+// writeJSONObject writes the values of the keys of m as JSON object.
+func writeJSONObject(buf jsonWriter, keys []string, m map[string][]byte) {
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, []byte(k))
+		buf.WriteByte(':')
+		writeJSONString(buf, m[k])
+	}
+	buf.WriteString("}\n")
+}
+
+// writeJSONString writes b as JSON string escaped like encoding/json does.
+func writeJSONString(buf jsonWriter, b []byte) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for i := 0; i < len(b); {
+		c := b[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c == '\n':
+				buf.WriteString(`\n`)
+			case c == '\r':
+				buf.WriteString(`\r`)
+			case c == '\t':
+				buf.WriteString(`\t`)
+			case c < 0x20 || c == '<' || c == '>' || c == '&':
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			default:
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(b[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.WriteRune(utf8.RuneError)
+		case r == '\u2028' || r == '\u2029':
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hex[r&0xf])
+		default:
+			buf.Write(b[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
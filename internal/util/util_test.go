@@ -0,0 +1,42 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteJSONObject(t *testing.T) {
+	m := map[string][]byte{
+		"message":  []byte("line 1\nline 2\t\"quoted\" \\ <b>&</b>"),
+		"password": []byte("pässwörd    \x00\x1f"),
+		"invalid":  {0xff, 'a', 0xc3},
+		"empty":    {},
+		"long":     bytes.Repeat([]byte("x"), 10000),
+	}
+	var got bytes.Buffer
+	if err := WriteJSONObject(&got, m); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]string, len(m))
+	for k, v := range m {
+		values[k] = string(v)
+	}
+	want, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, '\n')
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("WriteJSONObject = %s, want %s", got.Bytes(), want)
+	}
+
+	// The buffer is allocated at the exact size
+	keys := []string{"empty", "invalid", "long", "message", "password"}
+	var size byteCounter
+	writeJSONObject(&size, keys, m)
+	if int(size) != len(want) {
+		t.Errorf("counted %d bytes, want %d", size, len(want))
+	}
+}
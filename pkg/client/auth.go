@@ -0,0 +1,210 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Types of identity providers a server may federate with.
+const (
+	// ProviderEntra authenticates apps with client credentials at Microsoft Entra ID.
+	ProviderEntra = "microsoftonline"
+	// ProviderOIDC authenticates users interactively at an OpenID Connect
+	// provider such as Keycloak or Okta.
+	ProviderOIDC = "oidc"
+	// ProviderLocal authenticates local accounts with username and password.
+	ProviderLocal = "local"
+	// ProviderToken authenticates with a static API token.
+	ProviderToken = "token"
+)
+
+// Authenticator obtains access tokens for the API.
+type Authenticator interface {
+	// Authenticate returns a token response containing at least an access token.
+	Authenticate() (*TokenResponse, error)
+}
+
+// ClientCredentials authenticates an app registration at Microsoft Entra ID
+// with its client ID and secret.
+type ClientCredentials struct {
+	Client       *HTTP
+	ClientID     string
+	ClientSecret string
+}
+
+// Authenticate implements Authenticator.
+func (a *ClientCredentials) Authenticate() (*TokenResponse, error) {
+	token, err := a.Client.Login(a.ClientID, a.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{AccessToken: token}, nil
+}
+
+// LocalAccount authenticates a local account of the server with username
+// and password.
+type LocalAccount struct {
+	Client   *HTTP
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator.
+func (a *LocalAccount) Authenticate() (*TokenResponse, error) {
+	token, err := a.Client.LoginLocal(a.Username, a.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{AccessToken: token}, nil
+}
+
+// StaticToken authenticates with an API token issued by the server.
+type StaticToken string
+
+// Authenticate implements Authenticator.
+func (t StaticToken) Authenticate() (*TokenResponse, error) {
+	if t == "" {
+		return nil, errors.New("no API token provided")
+	}
+	return &TokenResponse{AccessToken: string(t)}, nil
+}
+
+// RefreshToken authenticates with the refresh token of an interactive login
+// at an OAuth authorization server. A rotated refresh token is contained in
+// the returned token response.
+type RefreshToken struct {
+	OAuth        *OAuth
+	RefreshToken string
+}
+
+// Authenticate implements Authenticator.
+func (a *RefreshToken) Authenticate() (*TokenResponse, error) {
+	if a.RefreshToken == "" {
+		return nil, errors.New("no refresh token provided")
+	}
+	return a.OAuth.Refresh(a.RefreshToken)
+}
+
+// Provider is an identity provider advertised by the server.
+type Provider struct {
+	// Type is one of ProviderEntra, ProviderOIDC, ProviderLocal or ProviderToken.
+	Type string `json:"type"`
+	// Name identifies the provider, e.g. keycloak.
+	Name string `json:"name"`
+	// Issuer is the issuer URL of an OpenID Connect provider. If empty, the
+	// server itself acts as authorization server.
+	Issuer string `json:"issuer"`
+	// ClientID is the client ID of the CLI at an OpenID Connect provider.
+	ClientID string `json:"client_id"`
+}
+
+// OAuth returns the authorization server of an OpenID Connect provider,
+// discovering its endpoints from the issuer.
+func (p *Provider) OAuth(h *HTTP) (*OAuth, error) {
+	if p.Issuer == "" {
+		o := h.OAuth()
+		if p.ClientID != "" {
+			o.ClientID = p.ClientID
+		}
+		return o, nil
+	}
+	clientID := p.ClientID
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+	return DiscoverOIDC(h.httpClient(), p.Issuer, clientID)
+}
+
+// AuthMetadata describes how to authenticate at the server.
+type AuthMetadata struct {
+	Providers []Provider `json:"providers"`
+}
+
+type authMetadataResponse struct {
+	Data AuthMetadata `json:"data"`
+}
+
+// GetAuthMetadata retrieves the identity providers advertised by the server.
+// It returns nil without an error if the server does not advertise any.
+func (h *HTTP) GetAuthMetadata() (*AuthMetadata, error) {
+	// Prepare the request
+	req, err := http.NewRequest("GET", h.APIURL+"/auth/_metadata", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the request
+	client := h.httpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Servers not advertising providers
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
+	}
+
+	// Read the response body
+	var response authMetadataResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
+	}
+	return &response.Data, nil
+}
+
+// FindProvider returns the provider with the given name or type. If name is
+// empty, the first provider is returned.
+func (m *AuthMetadata) FindProvider(name string) (*Provider, bool) {
+	for i, p := range m.Providers {
+		if name == "" || p.Name == name || p.Type == name {
+			return &m.Providers[i], true
+		}
+	}
+	return nil, false
+}
+
+// LoginLocal authenticates a local account with username and password and
+// returns an access token.
+func (h *HTTP) LoginLocal(username, password string) (string, error) {
+	// Prepare the request body
+	loginBody, err := json.Marshal(map[string]string{
+		"username": username,
+		"password": password,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal login request body: %v", err)
+	}
+
+	// Send the request
+	resp, err := h.httpClient().Post(h.APIURL+"/auth/local", "application/json", bytes.NewBuffer(loginBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to send login request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError(resp)
+	}
+
+	// Read the response body
+	var loginResp LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		return "", fmt.Errorf("failed to decode login response body: %v", err)
+	}
+	if loginResp.Data.AccessToken == "" {
+		return "", errors.New("no access token in login response")
+	}
+	return loginResp.Data.AccessToken, nil
+}
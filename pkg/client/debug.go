@@ -0,0 +1,209 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secret material in debug logs.
+const Redacted = "REDACTED"
+
+// maxDebugBody limits the logged size of error bodies.
+const maxDebugBody = 4096
+
+// sensitiveNames are names of headers, query parameters and JSON fields
+// whose values are never logged.
+var sensitiveNames = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"cipher":              true,
+	"password":            true,
+	"client_secret":       true,
+	"secret":              true,
+	"token":               true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"id_token":            true,
+	"code":                true,
+	"device_code":         true,
+	"code_verifier":       true,
+	"key":                 true,
+}
+
+var (
+	// bearerPattern matches credentials of authorization headers.
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	// jwtPattern matches JSON Web Tokens.
+	jwtPattern = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// dataURLPattern matches encrypted values.
+	dataURLPattern = regexp.MustCompile(`data:[A-Za-z0-9.+/-]+(;[A-Za-z0-9_=.-]+)*;base64,[A-Za-z0-9+/=_-]*`)
+	// jsonFieldPattern matches sensitive fields with string values in JSON
+	// embedded in text, possibly escaped. The value extends to the quote
+	// followed by the end of the field, so that quotes in the value do not
+	// end it early.
+	jsonFieldPattern = regexp.MustCompile(`(?i)\\?"(password|client_secret|secret|token|access_token|refresh_token|id_token|code|device_code|code_verifier|cipher)\\?"\s*:\s*\\?".*?\\?"(\s*[,}\]]|$)`)
+	// assignmentPattern matches sensitive names followed by their value,
+	// e.g. in form encoded or plain text bodies.
+	assignmentPattern = regexp.MustCompile(`(?i)\b(password|client_secret|secret|token|access_token|refresh_token|id_token|code|device_code|code_verifier|cipher)(\s*[=:]\s*)[^\s"'&,;}]+`)
+	// urlPattern matches URLs, which may contain credentials or keys.
+	urlPattern = regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s"'<>]+`)
+)
+
+// DebugTransport logs method, URL, duration and status of all requests, and
+// the body of error responses. Authorization headers, tokens, secrets,
+// encrypted values and link keys are never logged. Request bodies are not
+// logged at all.
+type DebugTransport struct {
+	// Base sends the requests, defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Logger receives the logs at debug level.
+	Logger *slog.Logger
+}
+
+func (t *DebugTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := []any{
+		slog.String("method", req.Method),
+		slog.String("url", RedactURL(req.URL)),
+	}
+	if headers := redactHeader(req.Header); len(headers) > 0 {
+		attrs = append(attrs, slog.Any("headers", headers))
+	}
+	t.Logger.Debug("request", attrs...)
+
+	start := time.Now()
+	resp, err := t.base().RoundTrip(req)
+	attrs = append(attrs[:2], slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", RedactString(err.Error())))
+		t.Logger.Debug("request failed", attrs...)
+		return nil, err
+	}
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+
+	// Log the error body, keeping it readable for the caller
+	if resp.StatusCode >= 400 && resp.Body != nil {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxDebugBody))
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if readErr == nil && len(body) > 0 {
+			attrs = append(attrs, slog.String("body", RedactBody(body)))
+		}
+	}
+	t.Logger.Debug("response", attrs...)
+	return resp, nil
+}
+
+// readCloser reads from a reader and closes the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// RedactURL formats the URL without fragment, password and values of
+// sensitive query parameters.
+func RedactURL(u *url.URL) string {
+	r := *u
+	if r.Fragment != "" || r.RawFragment != "" {
+		r.Fragment = Redacted
+		r.RawFragment = ""
+	}
+	if r.User != nil {
+		if _, ok := r.User.Password(); ok {
+			r.User = url.UserPassword(r.User.Username(), Redacted)
+		}
+	}
+	if r.RawQuery != "" {
+		query := r.Query()
+		for name := range query {
+			if sensitiveNames[strings.ToLower(name)] {
+				query[name] = []string{Redacted}
+			}
+		}
+		r.RawQuery = query.Encode()
+	}
+	return r.String()
+}
+
+// redactHeader returns the header with the values of sensitive headers
+// replaced.
+func redactHeader(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveNames[strings.ToLower(name)] {
+			headers[name] = Redacted
+		} else {
+			headers[name] = RedactString(strings.Join(values, ", "))
+		}
+	}
+	return headers
+}
+
+// RedactBody redacts a response body. Values of sensitive fields of JSON
+// bodies are replaced, other bodies are redacted with RedactString.
+func RedactBody(body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		b, err := json.Marshal(redactJSON(v))
+		if err == nil {
+			return string(b)
+		}
+	}
+	return RedactString(string(body))
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, value := range v {
+			if sensitiveNames[strings.ToLower(name)] {
+				v[name] = Redacted
+			} else {
+				v[name] = redactJSON(value)
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+		return v
+	case string:
+		return RedactString(v)
+	default:
+		return v
+	}
+}
+
+// RedactString replaces authorization credentials, JSON Web Tokens,
+// encrypted values, values of sensitive names and secrets in URLs of the
+// text.
+func RedactString(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Redacted
+		}
+		return RedactURL(u)
+	})
+	s = bearerPattern.ReplaceAllString(s, "${1} "+Redacted)
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = dataURLPattern.ReplaceAllString(s, Redacted)
+	s = jsonFieldPattern.ReplaceAllString(s, `"${1}":"`+Redacted+`"${2}`)
+	s = assignmentPattern.ReplaceAllString(s, "${1}${2}"+Redacted)
+	return s
+}
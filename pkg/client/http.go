@@ -3,14 +3,24 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 )
 
+// DefaultHTTPClient is the HTTP client used by clients without their own.
+// The CLI replaces it with a client configured with timeout and proxy.
+var DefaultHTTPClient = &http.Client{}
+
 type HTTP struct {
 	APIURL      string
 	AccessToken string
+	// Client sends the requests, defaults to DefaultHTTPClient.
+	Client *http.Client
+	// TokenSource provides access tokens renewed when expired, it takes
+	// precedence over AccessToken if set.
+	TokenSource TokenSource
 }
 
 func NewHTTP(apiURL, accessToken string) *HTTP {
@@ -20,6 +30,53 @@ func NewHTTP(apiURL, accessToken string) *HTTP {
 	}
 }
 
+// httpClient returns the HTTP client sending the requests.
+func (h *HTTP) httpClient() *http.Client {
+	if h.Client != nil {
+		return h.Client
+	}
+	return DefaultHTTPClient
+}
+
+// NewHTTPWithTokenSource creates a client authenticating with tokens of the token source.
+func NewHTTPWithTokenSource(apiURL string, ts TokenSource) *HTTP {
+	return &HTTP{
+		APIURL:      apiURL,
+		TokenSource: ts,
+	}
+}
+
+// StatusError is returned if the server responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("unexpected response status: %s with error: %v", e.Status, e.Message)
+	}
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+// IsUnauthorized reports whether the server requires (other) authentication.
+func (e *StatusError) IsUnauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// newStatusError creates a StatusError from the response including the error
+// message of the body if present.
+func newStatusError(resp *http.Response) *StatusError {
+	var errResp ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    errResp.Error,
+	}
+}
+
 type CreateResponse struct {
 	Identifier string `json:"identifier"`
 }
@@ -49,13 +106,12 @@ func (h *HTTP) Create(typeID int, cipher map[string]string, expiresAt string, vi
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if h.AccessToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.AccessToken)
+	if err := h.authorize(req); err != nil {
+		return nil, fmt.Errorf("could not authenticate: %v", err)
 	}
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := h.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
@@ -63,7 +119,7 @@ func (h *HTTP) Create(typeID int, cipher map[string]string, expiresAt string, vi
 
 	// Check the response status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+		return nil, newStatusError(resp)
 	}
 
 	// Read the response body
@@ -100,13 +156,12 @@ func (h *HTTP) Reveal(identifier string) (map[string]string, error) {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if h.AccessToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.AccessToken)
+	if err := h.authorize(req); err != nil {
+		return nil, fmt.Errorf("could not authenticate: %v", err)
 	}
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := h.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
@@ -114,7 +169,7 @@ func (h *HTTP) Reveal(identifier string) (map[string]string, error) {
 
 	// Check the response status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+		return nil, newStatusError(resp)
 	}
 
 	var revealResponse = &revealResponse{}
@@ -134,54 +189,195 @@ func (h *HTTP) Reveal(identifier string) (map[string]string, error) {
 	return mapCipher, nil
 }
 
+// CheckAuth validates the credentials against the server. The types are
+// served without authentication, so the cipher of a random identifier is
+// requested instead: the server answers with not found for accepted tokens
+// and with unauthorized or forbidden otherwise. No secret is revealed.
+func (h *HTTP) CheckAuth() error {
+	identifier, err := randomString(16)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/secret/%s/_cipher", h.APIURL, identifier), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := h.authorize(req); err != nil {
+		return fmt.Errorf("could not authenticate: %v", err)
+	}
+	if req.Header.Get("Authorization") == "" {
+		return errors.New("no access token")
+	}
+
+	// Send the request
+	resp, err := h.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	return nil
+}
+
+// Type describes a secret type and the fields a secret of this type consists of.
+type Type struct {
+	ID         int    `json:"id"`
+	Identifier string `json:"identifier"`
+	Name       struct {
+		En string `json:"en"`
+	} `json:"name"`
+	Fields []TypeField `json:"fields"`
+}
+
+// TypeField describes a single field of a secret type.
+type TypeField struct {
+	Identifier string `json:"identifier"`
+	Name       struct {
+		En string `json:"en"`
+	} `json:"name"`
+	Type      string `json:"type"`
+	Required  bool   `json:"required"`
+	Sensitive bool   `json:"sensitive"`
+}
+
+// Label returns the human readable name of the field, falling back to its identifier.
+func (f TypeField) Label() string {
+	if f.Name.En != "" {
+		return f.Name.En
+	}
+	return f.Identifier
+}
+
+// IsSensitive reports whether the field's value should not be echoed while typing.
+func (f TypeField) IsSensitive() bool {
+	return f.Sensitive || f.IsPassword()
+}
+
+// IsPassword reports whether the field holds a password which should be confirmed on input.
+func (f TypeField) IsPassword() bool {
+	return strings.EqualFold(f.Type, "password")
+}
+
+// IsMultiline reports whether the field accepts multi-line input.
+func (f TypeField) IsMultiline() bool {
+	return strings.EqualFold(f.Type, "textarea")
+}
+
 type typeResponse struct {
 	Data struct {
-		Types []struct {
-			ID         int    `json:"id"`
-			Identifier string `json:"identifier"`
-			Name       struct {
-				En string `json:"en"`
-			} `json:"name"`
-		} `json:"types"`
+		Types []Type `json:"types"`
 	} `json:"data"`
 }
 
 func (h *HTTP) CheckType(typeName string) (int, error) {
+	t, err := h.GetType(typeName)
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+// GetType retrieves the definition of the given secret type including its fields.
+func (h *HTTP) GetType(typeName string) (*Type, error) {
+	types, err := h.GetTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range types {
+		if strings.EqualFold(v.Identifier, typeName) {
+			t := v
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("type not found")
+}
+
+// GetTypes retrieves the definitions of all secret types.
+func (h *HTTP) GetTypes() ([]Type, error) {
 	// Prepare the request
 	req, err := http.NewRequest("GET", h.APIURL+"/type", nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if h.AccessToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.AccessToken)
+	if err := h.authorize(req); err != nil {
+		return nil, fmt.Errorf("could not authenticate: %v", err)
 	}
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := h.do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Check the response status code
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected response status: %s", resp.Status)
+		return nil, newStatusError(resp)
 	}
 
 	// Read the response body
 	var response typeResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return 0, fmt.Errorf("failed to decode response body: %v", err)
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
 	}
+	return response.Data.Types, nil
+}
 
-	for _, v := range response.Data.Types {
-		if strings.EqualFold(v.Identifier, typeName) {
-			return v.ID, nil
-		}
+// Limits describes the expiry and views limits advertised by the server.
+type Limits struct {
+	MinExpiresAt string `json:"min_expires_at"`
+	MaxExpiresAt string `json:"max_expires_at"`
+	MinViews     int    `json:"min_views"`
+	MaxViews     int    `json:"max_views"`
+}
+
+type limitsResponse struct {
+	Data Limits `json:"data"`
+}
+
+// GetLimits retrieves the limits for creating secrets advertised by the
+// server. It returns nil without an error if the server does not advertise any.
+func (h *HTTP) GetLimits() (*Limits, error) {
+	// Prepare the request
+	req, err := http.NewRequest("GET", h.APIURL+"/secret/_limits", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := h.authorize(req); err != nil {
+		return nil, fmt.Errorf("could not authenticate: %v", err)
+	}
+
+	// Send the request
+	resp, err := h.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Servers not advertising limits
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
+	}
+
+	// Read the response body
+	var response limitsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
 	}
-	return 0, fmt.Errorf("type not found")
+	return &response.Data, nil
 }
 
 type LoginResponse struct {
@@ -210,7 +406,7 @@ func (h *HTTP) Login(clientID, clientSecret string) (string, error) {
 	}
 
 	// Send the request
-	resp, err := http.Post(loginURL, "application/json", bytes.NewBuffer(loginBody))
+	resp, err := h.httpClient().Post(loginURL, "application/json", bytes.NewBuffer(loginBody))
 	if err != nil {
 		return "", fmt.Errorf("failed to send login request: %v", err)
 	}
@@ -218,10 +414,7 @@ func (h *HTTP) Login(clientID, clientSecret string) (string, error) {
 
 	// Check the response status code
 	if resp.StatusCode != http.StatusOK {
-		// Read the response body
-		var errResp ErrorResponse
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", fmt.Errorf("unexpected response status: %s with error: %v", resp.Status, errResp.Error)
+		return "", newStatusError(resp)
 	}
 
 	// Read the response body
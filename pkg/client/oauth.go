@@ -0,0 +1,443 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultClientID is the OAuth client ID of the CLI.
+const DefaultClientID = "secretify-cli"
+
+// OAuth is an OAuth 2.0 authorization server the CLI obtains tokens from,
+// either the Secretify server itself or an OpenID Connect provider.
+type OAuth struct {
+	// ClientID is the client ID of the CLI at the authorization server.
+	ClientID string
+	// DeviceAuthorizationEndpoint is the URL of the device authorization endpoint.
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	// AuthorizationEndpoint is the URL of the authorization endpoint.
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	// TokenEndpoint is the URL of the token endpoint.
+	TokenEndpoint string `json:"token_endpoint"`
+	// RevocationEndpoint is the URL of the token revocation endpoint, empty
+	// if tokens cannot be revoked.
+	RevocationEndpoint string `json:"revocation_endpoint"`
+	// HTTPClient sends the requests, defaults to DefaultHTTPClient.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// httpClient returns the HTTP client sending the requests.
+func (o *OAuth) httpClient() *http.Client {
+	if o.HTTPClient != nil {
+		return o.HTTPClient
+	}
+	return DefaultHTTPClient
+}
+
+// OAuth returns the authorization server of the Secretify server itself.
+func (h *HTTP) OAuth() *OAuth {
+	return &OAuth{
+		ClientID:                    DefaultClientID,
+		DeviceAuthorizationEndpoint: h.APIURL + "/auth/device",
+		AuthorizationEndpoint:       h.APIURL + "/auth/authorize",
+		TokenEndpoint:               h.APIURL + "/auth/token",
+		RevocationEndpoint:          h.APIURL + "/auth/revoke",
+		HTTPClient:                  h.Client,
+	}
+}
+
+// DiscoverOIDC retrieves the endpoints of an OpenID Connect provider from
+// its discovery document using the HTTP client, or DefaultHTTPClient if nil.
+func DiscoverOIDC(client *http.Client, issuer, clientID string) (*OAuth, error) {
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Get(strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send discovery request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	// Read the response body
+	o := &OAuth{ClientID: clientID, HTTPClient: client}
+	if err := json.NewDecoder(resp.Body).Decode(o); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %v", err)
+	}
+	if o.TokenEndpoint == "" {
+		return nil, errors.New("discovery document contains no token endpoint")
+	}
+	return o, nil
+}
+
+// OAuth grant types used by the CLI.
+const (
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// offlineAccessScope requests an ID token and a refresh token.
+const offlineAccessScope = "openid offline_access"
+
+// TokenResponse is the response of the OAuth token endpoint.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// OAuthError is an error returned by an OAuth endpoint (RFC 6749, section 5.2).
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// DeviceAuthorization is the response of the device authorization endpoint
+// (RFC 8628, section 3.2).
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// AuthorizeDevice starts the device authorization flow.
+func (o *OAuth) AuthorizeDevice() (*DeviceAuthorization, error) {
+	if o.DeviceAuthorizationEndpoint == "" {
+		return nil, errors.New("device authorization is not supported by the provider")
+	}
+	form := url.Values{
+		"client_id": {o.ClientID},
+		"scope":     {offlineAccessScope},
+	}
+
+	// Send the request
+	resp, err := o.httpClient().PostForm(o.DeviceAuthorizationEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send device authorization request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newOAuthError(resp)
+	}
+
+	// Read the response body
+	var da DeviceAuthorization
+	if err := json.NewDecoder(resp.Body).Decode(&da); err != nil {
+		return nil, fmt.Errorf("failed to decode device authorization response: %v", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, errors.New("incomplete device authorization response")
+	}
+	return &da, nil
+}
+
+// deviceTimeUnit is the unit of the polling interval and lifetime of device
+// authorizations. Tests shorten it.
+var deviceTimeUnit = time.Second
+
+// errDeviceCodeExpired reports that the user did not approve the device
+// authorization in time.
+var errDeviceCodeExpired = errors.New("device code expired")
+
+// PollDeviceToken polls the token endpoint until the user approved or denied
+// the device authorization, the device code expired or ctx is done.
+func (o *OAuth) PollDeviceToken(ctx context.Context, da *DeviceAuthorization) (*TokenResponse, error) {
+	interval := time.Duration(da.Interval) * deviceTimeUnit
+	if interval <= 0 {
+		interval = 5 * deviceTimeUnit
+	}
+	var expired <-chan time.Time
+	if da.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(da.ExpiresIn) * deviceTimeUnit)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	form := url.Values{
+		"grant_type":  {GrantTypeDeviceCode},
+		"device_code": {da.DeviceCode},
+		"client_id":   {o.ClientID},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, errDeviceCodeExpired
+		case <-time.After(interval):
+		}
+
+		token, err := o.requestToken(form)
+		var oauthErr *OAuthError
+		if errors.As(err, &oauthErr) {
+			switch oauthErr.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += 5 * deviceTimeUnit
+				continue
+			case "expired_token":
+				return nil, errDeviceCodeExpired
+			}
+		}
+		return token, err
+	}
+}
+
+// PKCE is a proof key for code exchange (RFC 7636) using the S256 method.
+type PKCE struct {
+	Verifier  string
+	Challenge string
+}
+
+// NewPKCE generates a random code verifier and its challenge.
+func NewPKCE() (*PKCE, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return &PKCE{
+		Verifier:  verifier,
+		Challenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// AuthorizationURL returns the URL of the authorization endpoint the user
+// has to open in the browser.
+func (o *OAuth) AuthorizationURL(redirectURI, state string, pkce *PKCE) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {offlineAccessScope},
+		"state":                 {state},
+		"code_challenge":        {pkce.Challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(o.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return o.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// ExchangeCode exchanges an authorization code for tokens.
+func (o *OAuth) ExchangeCode(code, redirectURI string, pkce *PKCE) (*TokenResponse, error) {
+	return o.requestToken(url.Values{
+		"grant_type":    {GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {o.ClientID},
+		"code_verifier": {pkce.Verifier},
+	})
+}
+
+// Refresh obtains a new access token with a refresh token. The server may
+// rotate the refresh token, in which case the response contains a new one.
+func (o *OAuth) Refresh(refreshToken string) (*TokenResponse, error) {
+	return o.requestToken(url.Values{
+		"grant_type":    {GrantTypeRefreshToken},
+		"refresh_token": {refreshToken},
+		"client_id":     {o.ClientID},
+	})
+}
+
+// Token type hints of the revocation endpoint (RFC 7009, section 2.1).
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// Revoke revokes the token at the revocation endpoint (RFC 7009). It reports
+// false without an error if the authorization server has no revocation
+// endpoint.
+func (o *OAuth) Revoke(token, tokenTypeHint string) (bool, error) {
+	if o.RevocationEndpoint == "" {
+		return false, nil
+	}
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+		"client_id":       {o.ClientID},
+	}
+
+	// Send the request
+	resp, err := o.httpClient().PostForm(o.RevocationEndpoint, form)
+	if err != nil {
+		return false, fmt.Errorf("failed to send revocation request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Servers without revocation endpoint
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return false, newOAuthError(resp)
+	}
+	return true, nil
+}
+
+// LoginBrowser runs the authorization code flow with PKCE. It listens on a
+// loopback address for the redirect, calls open with the authorization URL
+// and waits until the browser is redirected back or ctx is done.
+func (o *OAuth) LoginBrowser(ctx context.Context, open func(authURL string) error) (*TokenResponse, error) {
+	if o.AuthorizationEndpoint == "" {
+		return nil, errors.New("browser login is not supported by the provider")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for redirect: %v", err)
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr().String())
+
+	pkce, err := NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// Receive the authorization code on the loopback listener. Requests
+	// without the state, e.g. from other local processes or web pages, are
+	// rejected without ending the login.
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/callback" {
+				http.NotFound(w, r)
+				return
+			}
+			q := r.URL.Query()
+			if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+				http.Error(w, "Invalid state.", http.StatusBadRequest)
+				return
+			}
+			var res result
+			switch {
+			case q.Get("error") != "":
+				res.err = &OAuthError{Code: q.Get("error"), Description: q.Get("error_description")}
+			case q.Get("code") == "":
+				res.err = errors.New("no authorization code in redirect")
+			default:
+				res.code = q.Get("code")
+			}
+			if res.err != nil {
+				http.Error(w, "Login failed, you can close this window.", http.StatusBadRequest)
+			} else {
+				fmt.Fprintln(w, "Login succeeded, you can close this window.")
+			}
+			select {
+			case results <- res:
+			default:
+			}
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if err := open(o.AuthorizationURL(redirectURI, state, pkce)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.New("timed out waiting for the browser login")
+		}
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return o.ExchangeCode(res.code, redirectURI, pkce)
+	}
+}
+
+// requestToken sends a request to the token endpoint.
+func (o *OAuth) requestToken(form url.Values) (*TokenResponse, error) {
+	// Send the request
+	resp, err := o.httpClient().PostForm(o.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newOAuthError(resp)
+	}
+
+	// Read the response body
+	var token TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("no access token in token response")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type %q", token.TokenType)
+	}
+	return &token, nil
+}
+
+// newOAuthError creates an OAuthError from the response, falling back to a
+// StatusError if the body contains no OAuth error.
+func newOAuthError(resp *http.Response) error {
+	var oauthErr OAuthError
+	if err := json.NewDecoder(resp.Body).Decode(&oauthErr); err != nil || oauthErr.Code == "" {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return &oauthErr
+}
+
+// randomString returns n random bytes encoded as unpadded base64url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
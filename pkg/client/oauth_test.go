@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// oauthServer is an authorization server answering token requests with the
+// given responses in order, recording the forms of all token requests.
+type oauthServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	forms     []url.Values
+}
+
+func newOAuthServer(responses ...func(w http.ResponseWriter)) *oauthServer {
+	s := &oauthServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/device":
+			json.NewEncoder(w).Encode(DeviceAuthorization{DeviceCode: "device", UserCode: "ABCD-EFGH", VerificationURI: "https://example.com/device", ExpiresIn: 600, Interval: 1})
+		case "/token":
+			r.ParseForm()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.forms = append(s.forms, r.PostForm)
+			if len(s.responses) == 0 {
+				oauthError(w, "invalid_grant")
+				return
+			}
+			respond := s.responses[0]
+			s.responses = s.responses[1:]
+			respond(w)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+func (s *oauthServer) OAuth() *OAuth {
+	return &OAuth{
+		ClientID:                    DefaultClientID,
+		DeviceAuthorizationEndpoint: s.URL + "/device",
+		AuthorizationEndpoint:       s.URL + "/authorize",
+		TokenEndpoint:               s.URL + "/token",
+		HTTPClient:                  s.Client(),
+	}
+}
+
+func (s *oauthServer) Forms() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forms
+}
+
+func oauthError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(OAuthError{Code: code})
+}
+
+func respondError(code string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) { oauthError(w, code) }
+}
+
+func respondToken(token TokenResponse) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(token)
+	}
+}
+
+// shortenDeviceTimeUnit makes device flow intervals and expiries count in
+// milliseconds for the duration of the test.
+func shortenDeviceTimeUnit(t *testing.T) {
+	unit := deviceTimeUnit
+	deviceTimeUnit = time.Millisecond
+	t.Cleanup(func() { deviceTimeUnit = unit })
+}
+
+func TestPollDeviceToken(t *testing.T) {
+	shortenDeviceTimeUnit(t)
+	token := TokenResponse{AccessToken: "access", TokenType: "Bearer", RefreshToken: "refresh", ExpiresIn: 3600}
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		expiresIn int
+		wantErr   string
+		requests  int
+	}{
+		{"approved", []func(w http.ResponseWriter){respondError("authorization_pending"), respondError("slow_down"), respondError("authorization_pending"), respondToken(token)}, 0, "", 4},
+		{"denied", []func(w http.ResponseWriter){respondError("authorization_pending"), respondError("access_denied")}, 0, "access_denied", 2},
+		{"expired token", []func(w http.ResponseWriter){respondError("expired_token")}, 0, "device code expired", 1},
+		{"expired", nil, 1, "device code expired", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newOAuthServer(tt.responses...)
+			defer srv.Close()
+			o := srv.OAuth()
+
+			da, err := o.AuthorizeDevice()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expiresIn != 0 {
+				da.ExpiresIn, da.Interval = tt.expiresIn, 100
+			}
+			got, err := o.PollDeviceToken(context.Background(), da)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if *got != token {
+				t.Errorf("got token %+v, want %+v", got, token)
+			}
+
+			forms := srv.Forms()
+			if len(forms) != tt.requests {
+				t.Errorf("sent %d token requests, want %d", len(forms), tt.requests)
+			}
+			for _, form := range forms {
+				if form.Get("grant_type") != GrantTypeDeviceCode || form.Get("device_code") != "device" || form.Get("client_id") != DefaultClientID {
+					t.Errorf("got token request %v", form)
+				}
+			}
+		})
+	}
+}
+
+// TestPollDeviceTokenSlowDown checks that slow_down increases the interval
+// of all following requests by 5 units.
+func TestPollDeviceTokenSlowDown(t *testing.T) {
+	shortenDeviceTimeUnit(t)
+	srv := newOAuthServer(respondError("slow_down"), respondError("authorization_pending"), respondToken(TokenResponse{AccessToken: "access"}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := srv.OAuth().PollDeviceToken(context.Background(), &DeviceAuthorization{DeviceCode: "device", Interval: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, want := time.Since(start), 13*deviceTimeUnit; elapsed < want {
+		t.Errorf("polled for %s, want at least %s", elapsed, want)
+	}
+}
+
+func TestPollDeviceTokenCanceled(t *testing.T) {
+	shortenDeviceTimeUnit(t)
+	srv := newOAuthServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := srv.OAuth().PollDeviceToken(ctx, &DeviceAuthorization{DeviceCode: "device", ExpiresIn: 600})
+	if err != context.Canceled {
+		t.Errorf("error %v, want %v", err, context.Canceled)
+	}
+	if forms := srv.Forms(); len(forms) != 0 {
+		t.Errorf("sent %d token requests after cancellation", len(forms))
+	}
+}
+
+func TestNewPKCE(t *testing.T) {
+	pkce, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(pkce.Verifier))
+	if want := base64.RawURLEncoding.EncodeToString(sum[:]); pkce.Challenge != want {
+		t.Errorf("got challenge %q, want %q", pkce.Challenge, want)
+	}
+	if len(pkce.Verifier) < 43 || len(pkce.Verifier) > 128 {
+		t.Errorf("verifier %q has length %d, want 43 to 128", pkce.Verifier, len(pkce.Verifier))
+	}
+	other, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Verifier == pkce.Verifier {
+		t.Error("verifiers are not random")
+	}
+}
+
+// TestLoginBrowser redirects the browser back with a wrong and a missing
+// state first, which must be rejected without ending the login, and then
+// with the code. The code must be exchanged with the verifier of the
+// challenge sent to the authorization endpoint.
+func TestLoginBrowser(t *testing.T) {
+	token := TokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600}
+	srv := newOAuthServer(respondToken(token))
+	defer srv.Close()
+
+	var authQuery url.Values
+	open := func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		authQuery = u.Query()
+		if got := u.Scheme + "://" + u.Host + u.Path; got != srv.URL+"/authorize" {
+			t.Errorf("got authorization endpoint %s", got)
+		}
+		if authQuery.Get("code_challenge_method") != "S256" || authQuery.Get("response_type") != "code" {
+			t.Errorf("got authorization request %v", authQuery)
+		}
+
+		// The browser is redirected back asynchronously
+		go func() {
+			redirect := authQuery.Get("redirect_uri")
+			for _, tt := range []struct {
+				query  url.Values
+				status int
+			}{
+				{url.Values{"state": {"wrong"}, "code": {"forged"}}, http.StatusBadRequest},
+				{url.Values{"code": {"forged"}}, http.StatusBadRequest},
+				{url.Values{"state": {authQuery.Get("state")}, "code": {"code"}}, http.StatusOK},
+			} {
+				resp, err := http.Get(redirect + "?" + tt.query.Encode())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Errorf("redirect with %v answered with %d, want %d", tt.query, resp.StatusCode, tt.status)
+				}
+			}
+		}()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got, err := srv.OAuth().LoginBrowser(ctx, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != token {
+		t.Errorf("got token %+v, want %+v", got, token)
+	}
+
+	forms := srv.Forms()
+	if len(forms) != 1 {
+		t.Fatalf("sent %d token requests, want 1", len(forms))
+	}
+	form := forms[0]
+	if form.Get("grant_type") != GrantTypeAuthorizationCode || form.Get("code") != "code" || form.Get("redirect_uri") != authQuery.Get("redirect_uri") {
+		t.Errorf("got token request %v", form)
+	}
+	sum := sha256.Sum256([]byte(form.Get("code_verifier")))
+	if challenge := base64.RawURLEncoding.EncodeToString(sum[:]); challenge != authQuery.Get("code_challenge") {
+		t.Errorf("verifier %q does not match the challenge %q", form.Get("code_verifier"), authQuery.Get("code_challenge"))
+	}
+}
+
+func TestLoginBrowserDenied(t *testing.T) {
+	srv := newOAuthServer()
+	defer srv.Close()
+
+	open := func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		q := url.Values{"state": {u.Query().Get("state")}, "error": {"access_denied"}}
+		go func() {
+			resp, err := http.Get(u.Query().Get("redirect_uri") + "?" + q.Encode())
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := srv.OAuth().LoginBrowser(ctx, open)
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("error %v, want access_denied", err)
+	}
+	if forms := srv.Forms(); len(forms) != 0 {
+		t.Errorf("sent %d token requests after denial", len(forms))
+	}
+}
+
+// TestRefreshTokenRotated checks that a rotated refresh token is returned
+// for the caller to store.
+func TestRefreshTokenRotated(t *testing.T) {
+	srv := newOAuthServer(respondToken(TokenResponse{AccessToken: "access", RefreshToken: "rotated", ExpiresIn: 3600}))
+	defer srv.Close()
+
+	authenticator := &RefreshToken{OAuth: srv.OAuth(), RefreshToken: "refresh"}
+	got, err := authenticator.Authenticate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "access" || got.RefreshToken != "rotated" {
+		t.Errorf("got token %+v", got)
+	}
+	forms := srv.Forms()
+	if len(forms) != 1 || forms[0].Get("grant_type") != GrantTypeRefreshToken || forms[0].Get("refresh_token") != "refresh" {
+		t.Errorf("got token requests %v", forms)
+	}
+}
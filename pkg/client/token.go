@@ -0,0 +1,147 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryDelta is the time before the expiry at which a token is renewed.
+const expiryDelta = 30 * time.Second
+
+// unknownLifetime is the lifetime assumed for access tokens issued without
+// expiry, after which they are renewed.
+const unknownLifetime = 5 * time.Minute
+
+// Token is an access token together with its refresh token and expiry.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	// Expiry is the time the access token expires, zero if it never
+	// expires.
+	Expiry time.Time
+}
+
+// NewToken creates a token from a token response received now. Access
+// tokens issued without expiry are assumed to expire after a few minutes.
+func NewToken(resp *TokenResponse, now time.Time) *Token {
+	t := &Token{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+	if resp.ExpiresIn > 0 {
+		t.Expiry = now.Add(time.Duration(resp.ExpiresIn) * time.Second)
+	} else {
+		t.Expiry = now.Add(unknownLifetime)
+	}
+	return t
+}
+
+// Valid reports whether the token has an access token which does not expire
+// within the next seconds.
+func (t *Token) Valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.Expiry)
+}
+
+// TokenSource returns valid access tokens, renewing them when expired.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// Invalidator is implemented by token sources and authenticators which can
+// drop an access token rejected by the server, so that it is not returned
+// again.
+type Invalidator interface {
+	Invalidate(accessToken string)
+}
+
+// reuseTokenSource caches the token of an authenticator until it expires.
+type reuseTokenSource struct {
+	mu            sync.Mutex
+	authenticator Authenticator
+	token         *Token
+}
+
+// ReuseTokenSource returns a token source that authenticates with the
+// authenticator whenever the cached token expired.
+func ReuseTokenSource(token *Token, authenticator Authenticator) TokenSource {
+	return &reuseTokenSource{authenticator: authenticator, token: token}
+}
+
+// Token implements TokenSource.
+func (s *reuseTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+	resp, err := s.authenticator.Authenticate()
+	if err != nil {
+		return nil, err
+	}
+	s.token = NewToken(resp, time.Now())
+	return s.token, nil
+}
+
+// Invalidate implements Invalidator, dropping the cached token and the token
+// of the authenticator.
+func (s *reuseTokenSource) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && s.token.AccessToken == accessToken {
+		s.token = nil
+	}
+	if invalidator, ok := s.authenticator.(Invalidator); ok {
+		invalidator.Invalidate(accessToken)
+	}
+}
+
+// authorize sets the Authorization header of the request with a token of the
+// token source, or the static access token.
+func (h *HTTP) authorize(req *http.Request) error {
+	accessToken := h.AccessToken
+	if h.TokenSource != nil {
+		token, err := h.TokenSource.Token()
+		if err != nil {
+			return err
+		}
+		accessToken = token.AccessToken
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return nil
+}
+
+// do sends the request. If the server rejects the access token of the token
+// source, the token is dropped and the request is sent once more with a new
+// token.
+func (h *HTTP) do(req *http.Request) (*http.Response, error) {
+	resp, err := h.httpClient().Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	invalidator, ok := h.TokenSource.(Invalidator)
+	accessToken, bearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || !bearer || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	invalidator.Invalidate(accessToken)
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := h.authorize(retry); err != nil {
+		return nil, fmt.Errorf("could not authenticate: %v", err)
+	}
+	return h.httpClient().Do(retry)
+}
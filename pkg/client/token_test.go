@@ -0,0 +1,140 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingAuthenticator issues the tokens in order, without expiry.
+type countingAuthenticator struct {
+	tokens []string
+	calls  int
+}
+
+func (a *countingAuthenticator) Authenticate() (*TokenResponse, error) {
+	token := a.tokens[a.calls]
+	a.calls++
+	return &TokenResponse{AccessToken: token}, nil
+}
+
+// tokenServer accepts only the access token, recording the bodies of all
+// requests.
+func tokenServer(accessToken string, bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer "+accessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "token expired"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"identifier": "abc", "types": []Type{}}})
+	}))
+}
+
+func TestNewTokenUnknownExpiry(t *testing.T) {
+	now := time.Now()
+	token := NewToken(&TokenResponse{AccessToken: "a"}, now)
+	if !token.Expiry.Equal(now.Add(unknownLifetime)) || !token.Valid() {
+		t.Errorf("token without expiry expires at %s, want %s", token.Expiry, now.Add(unknownLifetime))
+	}
+	if NewToken(&TokenResponse{AccessToken: "a"}, now.Add(-unknownLifetime)).Valid() {
+		t.Error("token without expiry is valid after the assumed lifetime")
+	}
+	if token := NewToken(&TokenResponse{AccessToken: "a", ExpiresIn: 3600}, now); !token.Expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("token expires at %s, want %s", token.Expiry, now.Add(time.Hour))
+	}
+	if !(&Token{AccessToken: "a"}).Valid() {
+		t.Error("token which never expires is not valid")
+	}
+}
+
+// TestTokenRenewedOnUnauthorized checks that a cached token without expiry
+// rejected by the server is dropped and the request, including its body, is
+// sent once more with a new token.
+func TestTokenRenewedOnUnauthorized(t *testing.T) {
+	var bodies []string
+	srv := tokenServer("new", &bodies)
+	defer srv.Close()
+
+	authenticator := &countingAuthenticator{tokens: []string{"new"}}
+	h := NewHTTPWithTokenSource(srv.URL, ReuseTokenSource(&Token{AccessToken: "old"}, authenticator))
+	if _, err := h.Create(1, map[string]string{"message": "data:..."}, "24h", 1, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if authenticator.calls != 1 {
+		t.Errorf("authenticated %d times, want 1", authenticator.calls)
+	}
+	if len(bodies) != 2 || bodies[0] == "" || bodies[1] != bodies[0] {
+		t.Errorf("got bodies %q, want the same body twice", bodies)
+	}
+
+	// The new token is reused
+	if _, err := h.GetTypes(); err != nil {
+		t.Fatal(err)
+	}
+	if authenticator.calls != 1 || len(bodies) != 3 {
+		t.Errorf("authenticated %d times and sent %d requests, want 1 and 3", authenticator.calls, len(bodies))
+	}
+}
+
+func TestTokenRenewedOnlyOnce(t *testing.T) {
+	var bodies []string
+	srv := tokenServer("other", &bodies)
+	defer srv.Close()
+
+	authenticator := &countingAuthenticator{tokens: []string{"new", "newer"}}
+	h := NewHTTPWithTokenSource(srv.URL, ReuseTokenSource(&Token{AccessToken: "old"}, authenticator))
+	_, err := h.GetTypes()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("error %v, want unauthorized", err)
+	}
+	if authenticator.calls != 1 || len(bodies) != 2 {
+		t.Errorf("authenticated %d times and sent %d requests, want 1 and 2", authenticator.calls, len(bodies))
+	}
+
+	// Static access tokens are not renewed
+	bodies = nil
+	if _, err := NewHTTP(srv.URL, "old").GetTypes(); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(bodies) != 1 {
+		t.Errorf("sent %d requests, want 1", len(bodies))
+	}
+}
+
+// TestCheckAuth checks that credentials are validated against an endpoint
+// requiring authentication, renewing rejected tokens once.
+func TestCheckAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid token"})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "secret not found"})
+	}))
+	defer srv.Close()
+
+	authenticator := &countingAuthenticator{tokens: []string{"new"}}
+	if err := NewHTTPWithTokenSource(srv.URL, ReuseTokenSource(&Token{AccessToken: "old"}, authenticator)).CheckAuth(); err != nil {
+		t.Errorf("renewed token rejected: %v", err)
+	}
+	for _, token := range []string{"old", ""} {
+		err := NewHTTP(srv.URL, token).CheckAuth()
+		var statusErr *StatusError
+		if token != "" && (!errors.As(err, &statusErr) || !statusErr.IsUnauthorized()) {
+			t.Errorf("token %q: error %v, want unauthorized", token, err)
+		}
+		if err == nil {
+			t.Errorf("token %q accepted", token)
+		}
+	}
+}
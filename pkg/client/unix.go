@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// UnixScheme is the scheme of API URLs of instances listening on a Unix
+// domain socket, e.g. unix:///run/secretify/api.sock.
+const UnixScheme = "unix"
+
+// unixTransport sends requests of unix URLs over the Unix domain socket at
+// the beginning of the path, e.g. unix:///run/secretify/api.sock/api/v1/type
+// is sent as GET /api/v1/type over /run/secretify/api.sock.
+type unixTransport struct {
+	base *http.Transport
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, path, err := splitSocketPath(req.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewrite the request to plain HTTP on the socket
+	r := req.Clone(req.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = "localhost"
+	r.URL.Path = path
+	r.URL.RawPath = ""
+	r.Host = "localhost"
+	return t.transport(socket).RoundTrip(r)
+}
+
+// transport returns the transport dialing the socket, reused for all
+// requests to the socket.
+func (t *unixTransport) transport(socket string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transport, ok := t.transports[socket]; ok {
+		return transport
+	}
+	transport := t.base.Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	if t.transports == nil {
+		t.transports = make(map[string]*http.Transport)
+	}
+	t.transports[socket] = transport
+	return transport
+}
+
+// splitSocketPath splits the path of a unix URL into the path of the socket,
+// the shortest prefix that is a socket, and the remaining request path.
+func splitSocketPath(path string) (string, string, error) {
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+		if fi, err := os.Stat(path[:i]); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			rest := path[i:]
+			if rest == "" {
+				rest = "/"
+			}
+			return path[:i], rest, nil
+		}
+	}
+	return "", "", fmt.Errorf("no Unix domain socket found in %s", strings.TrimRight(path, "/"))
+}
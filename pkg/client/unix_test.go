@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// listenUnix listens on a Unix domain socket in a temporary directory. The
+// directory is created below the default temporary directory, as the path of
+// a socket is limited to about 100 bytes.
+func listenUnix(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "secretify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix domain sockets not supported: %v", err)
+	}
+	return listener, socket
+}
+
+func TestSplitSocketPath(t *testing.T) {
+	listener, socket := listenUnix(t)
+	defer listener.Close()
+	dir := filepath.Dir(socket)
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		socket string
+		rest   string
+		ok     bool
+	}{
+		{"request path", socket + "/api/v1/type", socket, "/api/v1/type", true},
+		{"socket only", socket, socket, "/", true},
+		{"trailing slash", socket + "/", socket, "/", true},
+		{"escaped characters kept", socket + "/api/v1/secret/a%2Fb", socket, "/api/v1/secret/a%2Fb", true},
+		{"socket name prefix", socket + "x/api/v1/type", "", "", false},
+		{"directory", dir + "/api/v1/type", "", "", false},
+		{"regular file", file + "/api/v1/type", "", "", false},
+		{"missing", filepath.Join(dir, "missing.sock") + "/api/v1/type", "", "", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket, rest, err := splitSocketPath(tt.path)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("got socket %q and path %q, want error", socket, rest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if socket != tt.socket || rest != tt.rest {
+				t.Errorf("got socket %q and path %q, want %q and %q", socket, rest, tt.socket, tt.rest)
+			}
+		})
+	}
+}
+
+// TestUnixTransport sends requests of the API client to a server listening
+// on a Unix domain socket, bypassing the configured proxy.
+func TestUnixTransport(t *testing.T) {
+	listener, socket := listenUnix(t)
+	var paths, hosts []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		hosts = append(hosts, r.Host)
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"types": []Type{{ID: 1, Identifier: "text"}}}})
+	}))
+	srv.Listener.Close()
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	client, err := NewHTTPClient(Options{Proxy: "http://192.0.2.1:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHTTP(UnixScheme+"://"+socket+"/api/v1", "")
+	h.Client = client
+	for i := 0; i < 2; i++ {
+		types, err := h.GetTypes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(types) != 1 || types[0].Identifier != "text" {
+			t.Errorf("got types %+v", types)
+		}
+	}
+	for i := range paths {
+		if paths[i] != "/api/v1/type" || hosts[i] != "localhost" {
+			t.Errorf("got request for %s at host %s, want /api/v1/type at localhost", paths[i], hosts[i])
+		}
+	}
+
+	// Requests without socket fail before dialing
+	h.APIURL = UnixScheme + "://" + filepath.Dir(socket) + "/missing.sock/api/v1"
+	if _, err := h.GetTypes(); err == nil {
+		t.Error("expected error for missing socket")
+	}
+}
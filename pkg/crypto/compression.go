@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// Compression is an algorithm to compress plaintexts before sealing.
+type Compression string
+
+const (
+	// CompressionNone does not compress plaintexts.
+	CompressionNone Compression = ""
+	// CompressionDeflate compresses plaintexts with raw deflate (RFC 1951).
+	CompressionDeflate Compression = "deflate"
+)
+
+// DefaultCompressionThreshold is the minimum plaintext size in bytes to be
+// compressed if no threshold is set in the options.
+const DefaultCompressionThreshold = 1024
+
+// MaxDecompressedSize limits the size of decompressed plaintexts, so a hostile
+// payload cannot exhaust memory when revealing.
+const MaxDecompressedSize = 8 << 20
+
+// ParseCompression parses the name of a compression algorithm, "none" and
+// the empty string select no compression.
+func ParseCompression(s string) (Compression, error) {
+	switch Compression(s) {
+	case CompressionNone, "none":
+		return CompressionNone, nil
+	case CompressionDeflate:
+		return CompressionDeflate, nil
+	default:
+		return CompressionNone, fmt.Errorf("unsupported compression %q. Use none or deflate", s)
+	}
+}
+
+// compress compresses the plaintext if it is at least threshold bytes long
+// and compression reduces its size. It returns the algorithm applied.
+func compress(plaintext []byte, c Compression, threshold int) ([]byte, Compression, error) {
+	if c == CompressionNone {
+		return plaintext, CompressionNone, nil
+	}
+	if c != CompressionDeflate {
+		return nil, CompressionNone, fmt.Errorf("unsupported compression %q", c)
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	if len(plaintext) < threshold {
+		return plaintext, CompressionNone, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, CompressionNone, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, CompressionNone, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, CompressionNone, err
+	}
+
+	compressed := buf.Bytes()
+	if len(compressed) >= len(plaintext) {
+		Wipe(compressed[:cap(compressed)])
+		return plaintext, CompressionNone, nil
+	}
+	return compressed, CompressionDeflate, nil
+}
+
+// decompress decompresses the data, failing if it exceeds MaxDecompressedSize.
+func decompress(data []byte, c Compression) ([]byte, error) {
+	if c != CompressionDeflate {
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		Wipe(buf.Bytes()[:buf.Cap()])
+		return nil, fmt.Errorf("could not decompress: %v", err)
+	}
+	if n > MaxDecompressedSize {
+		Wipe(buf.Bytes()[:buf.Cap()])
+		return nil, fmt.Errorf("decompressed size exceeds the limit of %d bytes", MaxDecompressedSize)
+	}
+	return buf.Bytes(), nil
+}
@@ -1,65 +1,174 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"io"
-	"strings"
 )
 
+// dataURLPrefix is the prefix of legacy encrypted values encoded as data URL.
+const dataURLPrefix = "data:" + mediaType + ";base64,"
+
 // GenerateEncryptionKeyString generates a random encryption key of length 32.
+// The key is locked in memory where supported, callers should release it
+// with Destroy after use.
 func GenerateEncryptionKeyString() ([]byte, error) {
 	// Choose the key length (256 bits for AES-256)
 	keyLength := 32
 
 	// Generate random bytes for the key
 	key := make([]byte, keyLength)
+	Lock(key)
 	_, err := rand.Read(key)
 	if err != nil {
+		Destroy(key)
 		return nil, err
 	}
 
 	return key, nil // Return the raw key bytes
 }
 
-// EncryptDataMap encrypts each value in the given map using AES encryption.
+// EncryptDataMap encrypts each value in the given map using AES-GCM as
+// legacy value, which can be revealed by the web app.
 func EncryptDataMap(dataMap map[string]string, key []byte) (map[string]string, error) {
-	m := make(map[string]string)
+	return EncryptDataMapWithOptions(dataMap, key, Options{})
+}
 
+// EncryptDataMapWithOptions encrypts each value in the given map according to
+// the options, adding a manifest authenticating the whole map if requested.
+func EncryptDataMapWithOptions(dataMap map[string]string, key []byte, opts Options) (map[string]string, error) {
+	plaintexts := make(map[string][]byte, len(dataMap))
 	for k, v := range dataMap {
-		encryptedValue, err := EncryptStringToDataURL(v, key)
+		plaintexts[k] = []byte(v)
+	}
+	defer WipeDataMap(plaintexts)
+	return SealDataMap(plaintexts, key, opts)
+}
+
+// SealDataMap encrypts each plaintext of the map according to the options,
+// adding a manifest authenticating the whole map if requested or required by
+// the key derivation. The plaintexts are not modified and should be wiped
+// with WipeDataMap after use.
+func SealDataMap(dataMap map[string][]byte, key []byte, opts Options) (map[string]string, error) {
+	m := make(map[string]string, len(dataMap))
+
+	// Readers require a manifest for derived keys
+	if opts.KDF != KDFNone {
+		opts.Manifest = true
+	}
+	for k, v := range dataMap {
+		encryptedValue, err := SealField(v, key, k, opts)
 		if err != nil {
 			return nil, err
 		}
 		m[k] = encryptedValue
 	}
+
+	if opts.Manifest {
+		if err := AddManifest(m, opts.Type, key); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
-// EncryptString encrypts a plaintext string using AES encryption.
-func EncryptString(plaintext string, key []byte) (string, error) {
-	block, err := aes.NewCipher([]byte(key))
+// DecryptDataMap verifies the manifest of the given map of data URLs and
+// decrypts each value except reserved fields, deriving the key of each field
+// if recorded. The plaintexts should be wiped with WipeDataMap after use.
+func DecryptDataMap(encryptedMap map[string]string, key []byte) (map[string][]byte, error) {
+	if _, err := VerifyManifest(encryptedMap, key); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string][]byte, len(encryptedMap))
+	for k, v := range encryptedMap {
+		if IsReservedField(k) {
+			continue
+		}
+		plaintext, err := OpenField(v, key, k)
+		if err != nil {
+			WipeDataMap(m)
+			return nil, err
+		}
+		m[k] = plaintext
+	}
+	return m, nil
+}
+
+// WipeDataMap wipes all values of the map.
+func WipeDataMap(m map[string][]byte) {
+	for _, v := range m {
+		Wipe(v)
+	}
+}
+
+// Encrypt encrypts the plaintext using AES-GCM and returns the nonce
+// followed by the ciphertext.
+func Encrypt(plaintext, key []byte) ([]byte, error) {
+	return encrypt(CipherAESGCM, plaintext, key, nil)
+}
+
+// Decrypt decrypts the nonce followed by the ciphertext as returned by
+// Encrypt. The plaintext should be wiped with Wipe after use.
+func Decrypt(data, key []byte) ([]byte, error) {
+	return decrypt(CipherAESGCM, data, key, nil)
+}
+
+// nonceReader is the source of nonces. Tests replace it to create
+// deterministic vectors.
+var nonceReader io.Reader = rand.Reader
+
+func encrypt(c Cipher, plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(c, key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(nonceReader, nonce); err != nil {
+		return nil, err
+	}
+
+	// Seal appends the ciphertext to the nonce
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(c Cipher, data, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(c, key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize+aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
 	}
 
-	ciphertext := aesGCM.Seal(nil, nonce, []byte(plaintext), nil)
+	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
+	return aead.Open(nil, nonce, ciphertextBytes, additionalData)
+}
+
+// EncryptToDataURL encrypts the plaintext and returns it as a data URL.
+func EncryptToDataURL(plaintext, key []byte) (string, error) {
+	return Seal(plaintext, key, Options{})
+}
+
+// DecryptFromDataURL decrypts a data URL using the provided key. The
+// plaintext should be wiped with Wipe after use.
+func DecryptFromDataURL(dataURL string, key []byte) ([]byte, error) {
+	return Open(dataURL, key)
+}
+
+// EncryptString encrypts a plaintext string using AES encryption.
+func EncryptString(plaintext string, key []byte) (string, error) {
+	b := []byte(plaintext)
+	defer Wipe(b)
 
-	// Combine nonce and ciphertext and encode as base64
-	encrypted := append(nonce, ciphertext...)
+	encrypted, err := Encrypt(b, key)
+	if err != nil {
+		return "", err
+	}
 	return base64.RawStdEncoding.EncodeToString(encrypted), nil
 }
 
@@ -72,7 +181,7 @@ func EncryptStringToDataURL(plaintext string, key []byte) (string, error) {
 	}
 
 	// Create the data URL
-	dataURL := "data:application/octet-stream;base64," + encryptedBase64
+	dataURL := dataURLPrefix + encryptedBase64
 	return dataURL, nil
 }
 
@@ -84,40 +193,24 @@ func DecryptString(ciphertext string, key []byte) (string, error) {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := aesGCM.NonceSize()
-	if len(decoded) < nonceSize {
-		return "", errors.New("ciphertext too short")
-	}
-
-	nonce, ciphertextBytes := decoded[:nonceSize], decoded[nonceSize:]
-
-	plaintext, err := aesGCM.Open(nil, nonce, []byte(ciphertextBytes), nil)
+	plaintext, err := Decrypt(decoded, key)
 	if err != nil {
 		return "", err
 	}
+	defer Wipe(plaintext)
 
 	return string(plaintext), nil
 }
 
 // DecryptStringFromDataURL decrypts a ciphertext string encoded as a data URL using the provided key.
+// Parameters recorded in the data URL such as padding are reverted transparently.
 func DecryptStringFromDataURL(ciphertext string, key []byte) (string, error) {
-	ciphertext = strings.Replace(ciphertext, "data:application/octet-stream;base64,", "", 1)
-
 	// Decrypt the cipher
-	plaintext, err := DecryptString(ciphertext, key)
+	plaintext, err := Open(ciphertext, key)
 	if err != nil {
 		return "", err
 	}
+	defer Wipe(plaintext)
 
-	return plaintext, nil
+	return string(plaintext), nil
 }
@@ -0,0 +1,431 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWipe(t *testing.T) {
+	b := []byte("v3ryS3ecure$")
+	Wipe(b)
+	if !isZero(b) {
+		t.Fatalf("buffer not wiped: %q", b)
+	}
+}
+
+func TestDestroyKey(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isZero(key) {
+		t.Fatal("generated key is zero")
+	}
+
+	Destroy(key)
+	if !isZero(key) {
+		t.Fatal("key not wiped")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	dataURL, err := EncryptToDataURL([]byte("v3ryS3ecure$"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The byte and string based APIs are interchangeable
+	s, err := DecryptStringFromDataURL(dataURL, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "v3ryS3ecure$" {
+		t.Fatalf("got %q", s)
+	}
+	b, err := DecryptFromDataURL(dataURL, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte("v3ryS3ecure$")) {
+		t.Fatalf("got %q", b)
+	}
+}
+
+func TestWipeDataMap(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	plaintexts := map[string]string{
+		"username": "admin",
+		"password": "v3ryS3ecure$",
+		"empty":    "",
+	}
+	encryptedMap, err := EncryptDataMap(plaintexts, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptedMap, err := DecryptDataMap(encryptedMap, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffers := make(map[string][]byte, len(decryptedMap))
+	for k, v := range decryptedMap {
+		if string(v) != plaintexts[k] {
+			t.Fatalf("%s: got %q, want %q", k, v, plaintexts[k])
+		}
+		buffers[k] = v
+	}
+
+	WipeDataMap(decryptedMap)
+	for k, v := range buffers {
+		if !isZero(v) {
+			t.Fatalf("%s not wiped: %q", k, v)
+		}
+	}
+}
+
+func TestDecryptDataMapInvalidKey(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	encryptedMap, err := EncryptDataMap(map[string]string{"message": "v3ryS3ecure$"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey := bytes.Repeat([]byte{1}, 32)
+	if _, err := DecryptDataMap(encryptedMap, otherKey); err == nil {
+		t.Fatal("expected decryption with wrong key to fail")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	for _, padding := range []Padding{PaddingNone, PaddingPadme, PaddingPow2} {
+		for _, plaintext := range []string{"", "1234", "v3ryS3ecure$", string(bytes.Repeat([]byte{0x80, 0}, 300))} {
+			dataURL, err := Seal([]byte(plaintext), key, Options{Padding: padding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecryptStringFromDataURL(dataURL, key)
+			if err != nil {
+				t.Fatalf("%s: %v", padding, err)
+			}
+			if got != plaintext {
+				t.Fatalf("%s: got %q, want %q", padding, got, plaintext)
+			}
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	opts := Options{Compression: CompressionDeflate, Padding: PaddingPadme}
+	tests := []struct {
+		plaintext  []byte
+		compressed bool
+	}{
+		{[]byte("v3ryS3ecure$"), false},
+		{bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\n"), 100), true},
+	}
+	for _, tt := range tests {
+		dataURL, err := Seal(tt.plaintext, key, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := ParseEnvelope(dataURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := e.Params.Compression == CompressionDeflate; compressed != tt.compressed {
+			t.Fatalf("compressed %v, want %v", compressed, tt.compressed)
+		}
+
+		got, err := Open(dataURL, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt.plaintext) {
+			t.Fatalf("got %q, want %q", got, tt.plaintext)
+		}
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	dataURL, err := Seal(make([]byte, MaxDecompressedSize+1), key, Options{Compression: CompressionDeflate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dataURL, key); err == nil {
+		t.Fatal("expected decompression exceeding the limit to fail")
+	}
+}
+
+func TestCiphers(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	for _, c := range []Cipher{CipherAESGCM, CipherXChaCha20Poly1305} {
+		dataURL, err := Seal([]byte("v3ryS3ecure$"), key, Options{Cipher: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := ParseEnvelope(dataURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ParseCipher(string(e.Params.Cipher)); got != c {
+			t.Fatalf("recorded cipher %q, want %q", got, c)
+		}
+
+		got, err := DecryptStringFromDataURL(dataURL, key)
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		if got != "v3ryS3ecure$" {
+			t.Fatalf("%s: got %q", c, got)
+		}
+	}
+}
+
+func TestRegisterSuite(t *testing.T) {
+	c := Cipher("chacha20-poly1305")
+	RegisterSuite(c, SuiteFunc(chacha20poly1305.New))
+	defer delete(suites, c)
+
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	dataURL, err := Seal([]byte("v3ryS3ecure$"), key, Options{Cipher: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dataURL, "data:application/octet-stream;alg=chacha20-poly1305;base64,") {
+		t.Errorf("data URL %q does not record the cipher", dataURL)
+	}
+	got, err := DecryptStringFromDataURL(dataURL, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "v3ryS3ecure$" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := ParseCipher("chacha20-poly1305"); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []Cipher{c, CipherAESGCM, "", "alg;m=1"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterSuite(%q) did not panic", name)
+				}
+			}()
+			RegisterSuite(name, SuiteFunc(chacha20poly1305.New))
+		}()
+	}
+}
+
+func TestKDFBindsField(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	encrypted, err := EncryptDataMapWithOptions(map[string]string{"username": "admin", "password": "v3ryS3ecure$"}, key, Options{KDF: KDFHKDFv1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptDataMap(encrypted, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer WipeDataMap(decrypted)
+	if string(decrypted["password"]) != "v3ryS3ecure$" {
+		t.Fatalf("got %q", decrypted["password"])
+	}
+
+	// Values cannot be moved to another field
+	encrypted["username"], encrypted["password"] = encrypted["password"], encrypted["username"]
+	if _, err := DecryptDataMap(encrypted, key); err == nil {
+		t.Fatal("expected decryption of swapped fields to fail")
+	}
+}
+
+// TestResealField checks that values sealed on their own are sealed again
+// with the key of their field, keeping cipher and padding.
+func TestResealField(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	sealed, err := Seal([]byte("v3ryS3ecure$"), key, Options{Cipher: CipherXChaCha20Poly1305, Padding: PaddingPadme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resealed, err := ResealField(sealed, key, "password", Options{KDF: KDFHKDFv1, Manifest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ParseEnvelope(resealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Params{Cipher: CipherXChaCha20Poly1305, KDF: KDFHKDFv1, Padding: PaddingPadme, Manifest: true}
+	if e.Params != want {
+		t.Errorf("resealed with %+v, want %+v", e.Params, want)
+	}
+	plaintext, err := OpenField(resealed, key, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Wipe(plaintext)
+	if string(plaintext) != "v3ryS3ecure$" {
+		t.Errorf("got %q", plaintext)
+	}
+	if _, err := OpenField(resealed, key, "username"); err == nil {
+		t.Error("resealed value opened as another field")
+	}
+
+	// Values already sealed as requested are kept
+	if got, err := ResealField(sealed, key, "password", Options{}); err != nil || got != sealed {
+		t.Errorf("ResealField = %q, %v, want the sealed value", got, err)
+	}
+
+	// Values sealed with another key are rejected
+	other, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(other)
+	if _, err := ResealField(sealed, other, "password", Options{KDF: KDFHKDFv1}); err == nil {
+		t.Error("value sealed with another key resealed")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	key, err := GenerateEncryptionKeyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(key)
+
+	for name, opts := range map[string]Options{
+		"manifest": {Manifest: true, Type: "password"},
+		"kdf":      {KDF: KDFHKDFv1, Type: "password"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			encrypt := func() map[string]string {
+				m, err := EncryptDataMapWithOptions(map[string]string{"username": "admin", "password": "v3ryS3ecure$"}, key, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return m
+			}
+
+			m, err := VerifyManifest(encrypt(), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Type != "password" || len(m.Fields) != 2 {
+				t.Fatalf("unexpected manifest %+v", m)
+			}
+
+			tests := map[string]func(m map[string]string){
+				"dropped field":     func(m map[string]string) { delete(m, "username") },
+				"added field":       func(m map[string]string) { m["username2"] = m["username"] },
+				"replaced value":    func(m map[string]string) { m["password"] = encrypt()["password"] },
+				"stripped manifest": func(m map[string]string) { delete(m, ManifestField) },
+				"stripped manifest and parameter": func(m map[string]string) {
+					delete(m, ManifestField)
+					for k, v := range m {
+						m[k] = strings.Replace(v, ";m=1", "", 1)
+					}
+				},
+				"changed type": func(m map[string]string) {
+					manifest, _ := parseManifest(m[ManifestField])
+					manifest.Type = "text"
+					b, _ := json.Marshal(manifest)
+					m[ManifestField] = manifestPrefix + base64.RawStdEncoding.EncodeToString(b)
+				},
+			}
+			for name, tamper := range tests {
+				t.Run(name, func(t *testing.T) {
+					m := encrypt()
+					tamper(m)
+					if _, err := DecryptDataMap(m, key); err == nil {
+						t.Fatal("expected verification to fail")
+					}
+				})
+			}
+		})
+	}
+
+	// Maps are legacy maps without manifest unless requested, so that the
+	// web app can reveal them
+	legacy, err := EncryptDataMapWithOptions(map[string]string{"message": "v3ryS3ecure$"}, key, Options{Type: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := legacy[ManifestField]; ok || len(legacy) != 1 {
+		t.Fatalf("got fields %v, want message only", legacy)
+	}
+	if !strings.HasPrefix(legacy["message"], dataURLPrefix) {
+		t.Fatalf("value %q lacks the legacy prefix %q", legacy["message"], dataURLPrefix)
+	}
+	if _, err := DecryptDataMap(legacy, key); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,298 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mediaType is the media type of the data URL of an envelope.
+const mediaType = "application/octet-stream"
+
+// Envelope is an encrypted value together with the parameters required to
+// decrypt it. It is encoded as data URL
+//
+//	data:application/octet-stream[;NAME=VALUE]...;base64,DATA
+//
+// Values without parameters are legacy values as created by the web app.
+// The parameters of other values are authenticated as additional data.
+type Envelope struct {
+	Params Params
+	Data   []byte
+}
+
+// Params are the parameters of an envelope recorded in the data URL.
+type Params struct {
+	// Cipher is the AEAD cipher suite, empty for the default CipherAESGCM.
+	Cipher Cipher
+	// KDF is the derivation of the key from the link key.
+	KDF KDF
+	// Compression is the algorithm the plaintext was compressed with.
+	Compression Compression
+	// Padding is the padding scheme applied to the plaintext before sealing.
+	Padding Padding
+	// Manifest records that the value is part of a map authenticated by a
+	// manifest, so that the manifest cannot be stripped unnoticed.
+	Manifest bool
+}
+
+// IsLegacy reports whether no parameters are set.
+func (p Params) IsLegacy() bool {
+	return p == Params{}
+}
+
+// header returns the data URL up to the comma preceding the data, which is
+// authenticated as additional data unless it is a legacy value.
+func (p Params) header() string {
+	var b strings.Builder
+	b.WriteString("data:" + mediaType)
+	if p.Cipher != "" {
+		b.WriteString(";alg=" + string(p.Cipher))
+	}
+	if p.KDF != KDFNone {
+		b.WriteString(";kdf=" + string(p.KDF))
+	}
+	if p.Compression != CompressionNone {
+		b.WriteString(";z=" + string(p.Compression))
+	}
+	if p.Padding != PaddingNone {
+		b.WriteString(";pad=" + string(p.Padding))
+	}
+	if p.Manifest {
+		b.WriteString(";m=1")
+	}
+	b.WriteString(";base64")
+	return b.String()
+}
+
+// additionalData returns the additional data authenticated together with the ciphertext.
+func (p Params) additionalData() []byte {
+	if p.IsLegacy() {
+		return nil
+	}
+	return []byte(p.header())
+}
+
+// String encodes the envelope as data URL.
+func (e *Envelope) String() string {
+	return e.Params.header() + "," + base64.RawStdEncoding.EncodeToString(e.Data)
+}
+
+// ParseEnvelope decodes an envelope from a data URL. For compatibility, a
+// plain base64 string is accepted as legacy value.
+func ParseEnvelope(dataURL string) (*Envelope, error) {
+	var e Envelope
+
+	data := dataURL
+	if strings.HasPrefix(dataURL, "data:") {
+		header, rest, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+		if !ok {
+			return nil, errors.New("invalid data URL")
+		}
+		data = rest
+
+		parts := strings.Split(header, ";")
+		if parts[0] != mediaType || parts[len(parts)-1] != "base64" {
+			return nil, fmt.Errorf("unsupported data URL type %q", header)
+		}
+		for _, p := range parts[1 : len(parts)-1] {
+			name, value, _ := strings.Cut(p, "=")
+			switch name {
+			case "alg":
+				c, err := ParseCipher(value)
+				if err != nil {
+					return nil, err
+				}
+				if c == CipherAESGCM {
+					return nil, errors.New("invalid data URL parameter alg")
+				}
+				e.Params.Cipher = c
+			case "kdf":
+				kdf, err := ParseKDF(value)
+				if err != nil {
+					return nil, err
+				}
+				if kdf != KDF(value) {
+					return nil, errors.New("invalid data URL parameter kdf")
+				}
+				e.Params.KDF = kdf
+			case "z":
+				compression, err := ParseCompression(value)
+				if err != nil {
+					return nil, err
+				}
+				if compression == CompressionNone {
+					return nil, errors.New("invalid data URL parameter z")
+				}
+				e.Params.Compression = compression
+			case "pad":
+				padding, err := ParsePadding(value)
+				if err != nil {
+					return nil, err
+				}
+				if padding == PaddingNone {
+					return nil, errors.New("invalid data URL parameter pad")
+				}
+				e.Params.Padding = padding
+			case "m":
+				if value != "1" {
+					return nil, errors.New("invalid data URL parameter m")
+				}
+				e.Params.Manifest = true
+			default:
+				return nil, fmt.Errorf("unsupported data URL parameter %q", name)
+			}
+		}
+	}
+
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	if err != nil {
+		return nil, err
+	}
+	e.Data = decoded
+	return &e, nil
+}
+
+// Options configure how values are sealed.
+type Options struct {
+	// Cipher is the AEAD cipher suite, defaults to CipherAESGCM.
+	Cipher Cipher
+	// KDF derives per-field keys from the link key, defaults to using the
+	// link key directly.
+	KDF KDF
+	// Compression compresses plaintexts before sealing, defaults to no compression.
+	Compression Compression
+	// CompressionThreshold is the minimum size of plaintexts to be compressed,
+	// defaults to DefaultCompressionThreshold.
+	CompressionThreshold int
+	// Padding hides the length of the plaintext, defaults to no padding.
+	Padding Padding
+	// Type is the type of the secret authenticated by the manifest of an
+	// encrypted data map.
+	Type string
+	// Manifest adds a manifest authenticating the whole map in
+	// EncryptDataMapWithOptions and marks each value as part of it, which
+	// clients without manifest support such as the web app cannot reveal.
+	// It is implied by a key derivation and must be set when sealing values
+	// to be added to such a map.
+	Manifest bool
+}
+
+// Seal encrypts the plaintext according to the options and returns the
+// envelope encoded as data URL.
+func Seal(plaintext, key []byte, opts Options) (string, error) {
+	return SealField(plaintext, key, "", opts)
+}
+
+// SealField encrypts the plaintext of the named field according to the
+// options and returns the envelope encoded as data URL. If a key derivation
+// is set, the envelope can only be opened under the same field name.
+func SealField(plaintext, key []byte, field string, opts Options) (string, error) {
+	compressed, compression, err := compress(plaintext, opts.Compression, opts.CompressionThreshold)
+	if err != nil {
+		return "", err
+	}
+	if compression != CompressionNone {
+		defer Wipe(compressed)
+	}
+	params := Params{KDF: opts.KDF, Compression: compression, Padding: opts.Padding, Manifest: opts.Manifest}
+	if opts.Cipher != CipherAESGCM {
+		params.Cipher = opts.Cipher
+	}
+
+	padded, err := pad(compressed, params.Padding)
+	if err != nil {
+		return "", err
+	}
+	if params.Padding != PaddingNone {
+		defer Wipe(padded)
+	}
+
+	fieldKey, release, err := envelopeKey(params.KDF, key, field)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	data, err := encrypt(params.Cipher, padded, fieldKey, params.additionalData())
+	if err != nil {
+		return "", err
+	}
+	e := Envelope{Params: params, Data: data}
+	return e.String(), nil
+}
+
+// ResealField opens an envelope sealed on its own, e.g. by Seal, and seals
+// its plaintext again as the named field with the key derivation and
+// manifest marker of the options, keeping the cipher, compression and
+// padding of the envelope. Envelopes already sealed that way are returned
+// unchanged.
+func ResealField(dataURL string, key []byte, field string, opts Options) (string, error) {
+	e, err := ParseEnvelope(dataURL)
+	if err != nil {
+		return "", err
+	}
+	if opts.KDF == KDFNone && e.Params.KDF == KDFNone && e.Params.Manifest == opts.Manifest {
+		return dataURL, nil
+	}
+
+	plaintext, err := Open(dataURL, key)
+	if err != nil {
+		return "", err
+	}
+	defer Wipe(plaintext)
+	return SealField(plaintext, key, field, Options{
+		Cipher:      e.Params.Cipher,
+		KDF:         opts.KDF,
+		Compression: e.Params.Compression,
+		Padding:     e.Params.Padding,
+		Manifest:    opts.Manifest,
+	})
+}
+
+// Open decrypts an envelope encoded as data URL and reverts the transformations
+// recorded in its parameters. The plaintext should be wiped with Wipe after use.
+func Open(dataURL string, key []byte) ([]byte, error) {
+	return OpenField(dataURL, key, "")
+}
+
+// OpenField decrypts the envelope of the named field encoded as data URL and
+// reverts the transformations recorded in its parameters. The plaintext
+// should be wiped with Wipe after use.
+func OpenField(dataURL string, key []byte, field string) ([]byte, error) {
+	e, err := ParseEnvelope(dataURL)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldKey, release, err := envelopeKey(e.Params.KDF, key, field)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	plaintext, err := decrypt(e.Params.Cipher, e.Data, fieldKey, e.Params.additionalData())
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Params.Padding != PaddingNone {
+		unpadded, err := unpad(plaintext)
+		if err != nil {
+			Wipe(plaintext)
+			return nil, err
+		}
+		plaintext = unpadded
+	}
+
+	if e.Params.Compression != CompressionNone {
+		decompressed, err := decompress(plaintext, e.Params.Compression)
+		Wipe(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = decompressed
+	}
+	return plaintext, nil
+}
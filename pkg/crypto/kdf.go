@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// KDF identifies how the key of an envelope is derived from the link key.
+type KDF string
+
+const (
+	// KDFNone uses the link key directly.
+	KDFNone KDF = ""
+	// KDFHKDFv1 derives a per-secret key from the link key and from it a
+	// per-field key bound to the field name, both with HKDF-SHA256.
+	KDFHKDFv1 KDF = "hkdf-sha256-v1"
+)
+
+const (
+	// infoSecret is the HKDF info of the per-secret key.
+	infoSecret = "secretify/v1/secret"
+	// infoField is the HKDF info prefix of per-field keys, followed by the field name.
+	infoField = "secretify/v1/field/"
+)
+
+// ParseKDF parses the name of a key derivation, "none" and the empty string
+// select no key derivation.
+func ParseKDF(s string) (KDF, error) {
+	switch KDF(s) {
+	case KDFNone, "none":
+		return KDFNone, nil
+	case KDFHKDFv1, "hkdf":
+		return KDFHKDFv1, nil
+	default:
+		return KDFNone, fmt.Errorf("unsupported key derivation %q. Use none or hkdf", s)
+	}
+}
+
+// DeriveKey derives a 32 byte subkey for the given purpose from the key
+// with HKDF-SHA256. The subkey is locked in memory where supported and
+// should be released with Destroy after use.
+func DeriveKey(key []byte, info string) ([]byte, error) {
+	subkey := make([]byte, 32)
+	Lock(subkey)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(info)), subkey); err != nil {
+		Destroy(subkey)
+		return nil, err
+	}
+	return subkey, nil
+}
+
+// SecretKey derives the per-secret key from the link key.
+func SecretKey(linkKey []byte) ([]byte, error) {
+	return DeriveKey(linkKey, infoSecret)
+}
+
+// FieldKey derives the key of the field from the link key.
+func FieldKey(linkKey []byte, field string) ([]byte, error) {
+	secretKey, err := SecretKey(linkKey)
+	if err != nil {
+		return nil, err
+	}
+	defer Destroy(secretKey)
+	return DeriveKey(secretKey, infoField+field)
+}
+
+// envelopeKey returns the key to seal or open the field of an envelope. The
+// returned release function must be called after use.
+func envelopeKey(k KDF, linkKey []byte, field string) ([]byte, func(), error) {
+	switch k {
+	case KDFNone:
+		return linkKey, func() {}, nil
+	case KDFHKDFv1:
+		key, err := FieldKey(linkKey, field)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, func() { Destroy(key) }, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key derivation %q", k)
+	}
+}
@@ -0,0 +1,187 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ManifestField is the reserved field of an encrypted data map holding the
+// manifest authenticating the whole map.
+const ManifestField = "_manifest"
+
+// IsReservedField reports whether the field name is reserved for fields
+// such as the manifest, which start with an underscore.
+func IsReservedField(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+// ManifestVersion is the version of the manifest format.
+const ManifestVersion = 1
+
+// manifestPrefix is the data URL prefix of a manifest.
+const manifestPrefix = "data:application/vnd.secretify.manifest+json;base64,"
+
+// infoManifest is the HKDF info of the manifest key derived from the per-secret key.
+const infoManifest = "secretify/v1/manifest"
+
+// Manifest lists the fields of an encrypted data map together with the
+// type of the secret. Its MAC covers the version, the type, the number of
+// fields and the name and value of each field, so that fields cannot be
+// dropped, added, duplicated or replaced without knowing the link key.
+type Manifest struct {
+	Version int      `json:"v"`
+	Type    string   `json:"type"`
+	Fields  []string `json:"fields"`
+	MAC     string   `json:"mac"`
+}
+
+// ManifestKey derives the key of the manifest MAC from the link key.
+func ManifestKey(linkKey []byte) ([]byte, error) {
+	secretKey, err := SecretKey(linkKey)
+	if err != nil {
+		return nil, err
+	}
+	defer Destroy(secretKey)
+	return DeriveKey(secretKey, infoManifest)
+}
+
+// AddManifest computes the manifest over all fields of the encrypted map and
+// stores it in ManifestField, replacing an existing manifest.
+func AddManifest(encryptedMap map[string]string, secretType string, linkKey []byte) error {
+	delete(encryptedMap, ManifestField)
+	fields := sortedFields(encryptedMap)
+
+	mac, err := manifestMAC(encryptedMap, fields, ManifestVersion, secretType, linkKey)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(Manifest{
+		Version: ManifestVersion,
+		Type:    secretType,
+		Fields:  fields,
+		MAC:     base64.RawURLEncoding.EncodeToString(mac),
+	})
+	if err != nil {
+		return err
+	}
+	encryptedMap[ManifestField] = manifestPrefix + base64.RawStdEncoding.EncodeToString(b)
+	return nil
+}
+
+// VerifyManifest verifies that the encrypted map matches its manifest and
+// returns the manifest. Maps without manifest, as created by the web app,
+// are accepted and nil is returned, unless a value records that it belongs
+// to a manifest or uses key derivation, in which case the manifest is
+// required.
+func VerifyManifest(encryptedMap map[string]string, linkKey []byte) (*Manifest, error) {
+	encoded, ok := encryptedMap[ManifestField]
+	if !ok {
+		for k, v := range encryptedMap {
+			e, err := ParseEnvelope(v)
+			if err != nil {
+				continue
+			}
+			if e.Params.Manifest {
+				return nil, fmt.Errorf("manifest missing although field %q belongs to a manifest", k)
+			}
+			if e.Params.KDF != KDFNone {
+				return nil, fmt.Errorf("manifest missing although field %q uses key derivation", k)
+			}
+		}
+		return nil, nil
+	}
+
+	m, err := parseManifest(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+
+	// The fields of the map must be exactly those of the manifest
+	fields := sortedFields(encryptedMap)
+	if !slices.Equal(fields, m.Fields) {
+		return nil, errors.New("fields do not match the manifest")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(m.MAC)
+	if err != nil {
+		return nil, errors.New("invalid manifest MAC")
+	}
+	expected, err := manifestMAC(encryptedMap, fields, m.Version, m.Type, linkKey)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, errors.New("manifest verification failed")
+	}
+	return m, nil
+}
+
+func parseManifest(encoded string) (*Manifest, error) {
+	if !strings.HasPrefix(encoded, manifestPrefix) {
+		return nil, errors.New("invalid manifest")
+	}
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(encoded, manifestPrefix), "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return &m, nil
+}
+
+// sortedFields returns the sorted field names of the map without the manifest.
+func sortedFields(encryptedMap map[string]string) []string {
+	fields := make([]string, 0, len(encryptedMap))
+	for k := range encryptedMap {
+		if k != ManifestField {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// manifestMAC computes HMAC-SHA256 over the length-prefixed version, type,
+// field count and, for each field in order, its name and the SHA-256 digest
+// of its value.
+func manifestMAC(encryptedMap map[string]string, fields []string, version int, secretType string, linkKey []byte) ([]byte, error) {
+	key, err := ManifestKey(linkKey)
+	if err != nil {
+		return nil, err
+	}
+	defer Destroy(key)
+
+	mac := hmac.New(sha256.New, key)
+	writeUint32 := func(n int) {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		mac.Write(b[:])
+	}
+	writeString := func(s string) {
+		writeUint32(len(s))
+		mac.Write([]byte(s))
+	}
+
+	writeUint32(version)
+	writeString(secretType)
+	writeUint32(len(fields))
+	for _, f := range fields {
+		writeString(f)
+		digest := sha256.Sum256([]byte(encryptedMap[f]))
+		mac.Write(digest[:])
+	}
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,29 @@
+package crypto
+
+import "runtime"
+
+// Wipe overwrites the buffer with zeros.
+func Wipe(b []byte) {
+	clear(b)
+	// Keep the buffer alive until it is cleared so the writes are not elided
+	runtime.KeepAlive(b)
+}
+
+// Lock prevents the buffer from being swapped to disk where supported by the
+// kernel. Failures are ignored as locking is best effort, e.g. if
+// RLIMIT_MEMLOCK is exceeded.
+func Lock(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	_ = mlock(b)
+}
+
+// Destroy wipes and unlocks a buffer previously locked with Lock.
+func Destroy(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	Wipe(b)
+	_ = munlock(b)
+}
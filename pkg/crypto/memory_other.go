@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package crypto
+
+func mlock(b []byte) error {
+	return nil
+}
+
+func munlock(b []byte) error {
+	return nil
+}
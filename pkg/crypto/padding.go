@@ -0,0 +1,100 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Padding is a scheme to pad plaintexts before sealing to hide their length.
+type Padding string
+
+const (
+	// PaddingNone leaks the exact length of the plaintext.
+	PaddingNone Padding = ""
+	// PaddingPadme pads to the lengths of the Padmé scheme, leaking at most
+	// O(log log L) bits with an overhead of at most 12%.
+	PaddingPadme Padding = "padme"
+	// PaddingPow2 pads to the next power of two.
+	PaddingPow2 Padding = "pow2"
+)
+
+// minPaddedLength is the smallest bucket of all padding schemes, so short
+// values such as PINs and passwords are indistinguishable.
+const minPaddedLength = 32
+
+// paddingMarker separates the plaintext from the zero padding (ISO/IEC 7816-4).
+const paddingMarker = 0x80
+
+// ParsePadding parses the name of a padding scheme, "none" and the empty
+// string select no padding.
+func ParsePadding(s string) (Padding, error) {
+	switch Padding(s) {
+	case PaddingNone, "none":
+		return PaddingNone, nil
+	case PaddingPadme, PaddingPow2:
+		return Padding(s), nil
+	default:
+		return PaddingNone, fmt.Errorf("unsupported padding %q. Use none, padme or pow2", s)
+	}
+}
+
+// paddedLength returns the length the plaintext of length n including the
+// marker is padded to.
+func paddedLength(n int, p Padding) int {
+	if p != PaddingNone && n <= minPaddedLength {
+		return minPaddedLength
+	}
+	switch p {
+	case PaddingPadme:
+		return padmeLength(n)
+	case PaddingPow2:
+		return 1 << bits.Len(uint(n-1))
+	default:
+		return n
+	}
+}
+
+// padmeLength implements Padmé from "Reducing Metadata Leakage from Encrypted
+// Files and Communication with PURBs" (Nikitin et al., 2019).
+func padmeLength(n int) int {
+	if n < 2 {
+		return n
+	}
+	e := bits.Len(uint(n)) - 1
+	s := bits.Len(uint(e))
+	lastBits := e - s
+	mask := (1 << lastBits) - 1
+	return (n + mask) &^ mask
+}
+
+// pad appends the marker and zeros up to the length of the padding scheme.
+// Without padding, the plaintext is returned unchanged.
+func pad(plaintext []byte, p Padding) ([]byte, error) {
+	if p == PaddingNone {
+		return plaintext, nil
+	}
+	if _, err := ParsePadding(string(p)); err != nil {
+		return nil, err
+	}
+
+	padded := make([]byte, paddedLength(len(plaintext)+1, p))
+	copy(padded, plaintext)
+	padded[len(plaintext)] = paddingMarker
+	return padded, nil
+}
+
+// unpad strips the zeros and the marker appended by pad.
+func unpad(padded []byte) ([]byte, error) {
+	for i := len(padded) - 1; i >= 0; i-- {
+		switch padded[i] {
+		case 0:
+			continue
+		case paddingMarker:
+			return padded[:i], nil
+		default:
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return nil, errors.New("invalid padding")
+}
@@ -0,0 +1,100 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// WrappedKeyField is the reserved field of an encrypted data map holding the
+// key wrapped to the public keys of the recipients.
+const WrappedKeyField = "_wrapped_key"
+
+// wrappedKeyPrefix is the data URL prefix of a wrapped key.
+const wrappedKeyPrefix = "data:application/age-encryption;base64,"
+
+// ParseRecipients parses X25519 public keys (age1...) given directly or
+// read from files containing one public key per line. Empty lines and lines
+// starting with # are ignored.
+func ParseRecipients(publicKeys []string, files []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, k := range publicKeys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %v", k, err)
+		}
+		recipients = append(recipients, r)
+	}
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open recipient file: %v", err)
+		}
+		rs, err := age.ParseRecipients(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient file %s: %v", path, err)
+		}
+		recipients = append(recipients, rs...)
+	}
+	return recipients, nil
+}
+
+// LoadIdentities reads X25519 private keys (AGE-SECRET-KEY-1...) from the
+// given identity file.
+func LoadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open identity file: %v", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity file %s: %v", path, err)
+	}
+	return identities, nil
+}
+
+// WrapKey encrypts the key to the given recipients and returns it as data URL.
+func WrapKey(key []byte, recipients []age.Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("no recipients provided")
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(key); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return wrappedKeyPrefix + base64.RawStdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// UnwrapKey decrypts a key wrapped with WrapKey using one of the identities.
+func UnwrapKey(wrapped string, identities []age.Identity) ([]byte, error) {
+	if !strings.HasPrefix(wrapped, wrappedKeyPrefix) {
+		return nil, errors.New("invalid wrapped key")
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(wrapped, wrappedKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(decoded), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
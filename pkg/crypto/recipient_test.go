@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func generateIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWrapKey(t *testing.T) {
+	alice, bob, mallory := generateIdentity(t), generateIdentity(t), generateIdentity(t)
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	recipientFile := writeTestFile(t, "recipients", "# team\n\n"+bob.Recipient().String()+"\n")
+	recipients, err := ParseRecipients([]string{" " + alice.Recipient().String() + "\n"}, []string{recipientFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("parsed %d recipients, want 2", len(recipients))
+	}
+	wrapped, err := WrapKey(key, recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wrapped, wrappedKeyPrefix) {
+		t.Errorf("wrapped key %q lacks prefix %q", wrapped, wrappedKeyPrefix)
+	}
+
+	// Each recipient unwraps the key with an identity file as written by keygen
+	for name, identity := range map[string]*age.X25519Identity{"alice": alice, "bob": bob} {
+		identityFile := writeTestFile(t, "id", "# public key: "+identity.Recipient().String()+"\n"+identity.String()+"\n")
+		identities, err := LoadIdentities(identityFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := UnwrapKey(wrapped, identities)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Errorf("%s unwrapped %x, want %x", name, got, key)
+		}
+	}
+
+	// Other identities cannot unwrap the key
+	if _, err := UnwrapKey(wrapped, []age.Identity{mallory}); err == nil {
+		t.Error("key unwrapped with the wrong identity")
+	}
+
+	// Tampered wrapped keys are rejected
+	for name, tampered := range map[string]string{
+		"prefix":    strings.Replace(wrapped, "age-encryption", "octet-stream", 1),
+		"base64":    wrapped + "!",
+		"truncated": wrapped[:len(wrapped)-8],
+	} {
+		if _, err := UnwrapKey(tampered, []age.Identity{alice}); err == nil {
+			t.Errorf("%s: tampered key unwrapped", name)
+		}
+	}
+
+	if _, err := WrapKey(key, nil); err == nil {
+		t.Error("key wrapped to no recipients")
+	}
+}
+
+func TestParseRecipientsInvalid(t *testing.T) {
+	identity := generateIdentity(t)
+	for name, key := range map[string]string{
+		"empty":    "",
+		"invalid":  "age1invalid",
+		"identity": identity.String(),
+		"ssh":      "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHXqQoCt5dThyAr4ma+0qiGb0V8UfyoxaE1UmYO8D4Wx",
+	} {
+		if _, err := ParseRecipients([]string{key}, nil); err == nil {
+			t.Errorf("%s: recipient %q accepted", name, key)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"empty":    "",
+		"comments": "# no recipients\n",
+		"invalid":  identity.Recipient().String() + "\nage1invalid\n",
+		"identity": identity.String() + "\n",
+	} {
+		path := writeTestFile(t, "recipients", content)
+		if _, err := ParseRecipients(nil, []string{path}); err == nil {
+			t.Errorf("%s: recipient file %q accepted", name, content)
+		}
+	}
+	if _, err := ParseRecipients(nil, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("missing recipient file accepted")
+	}
+}
+
+func TestLoadIdentitiesInvalid(t *testing.T) {
+	identity := generateIdentity(t)
+	for name, content := range map[string]string{
+		"empty":     "",
+		"recipient": identity.Recipient().String() + "\n",
+		"invalid":   "AGE-SECRET-KEY-1INVALID\n",
+	} {
+		path := writeTestFile(t, "id", content)
+		if _, err := LoadIdentities(path); err == nil {
+			t.Errorf("%s: identity file %q accepted", name, content)
+		}
+	}
+	if _, err := LoadIdentities(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing identity file accepted")
+	}
+}
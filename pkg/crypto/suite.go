@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher identifies an AEAD cipher suite.
+type Cipher string
+
+const (
+	// CipherAESGCM is AES-256-GCM with random 96-bit nonces, the default and
+	// the cipher of legacy values.
+	CipherAESGCM Cipher = "aes-256-gcm"
+	// CipherXChaCha20Poly1305 is XChaCha20-Poly1305 with random 192-bit
+	// nonces, which is fast without AES instructions and safe for any
+	// number of messages per key.
+	CipherXChaCha20Poly1305 Cipher = "xchacha20-poly1305"
+)
+
+// Suite creates the AEAD of a cipher suite for a key.
+type Suite interface {
+	NewAEAD(key []byte) (cipher.AEAD, error)
+}
+
+// SuiteFunc adapts a function to the Suite interface.
+type SuiteFunc func(key []byte) (cipher.AEAD, error)
+
+// NewAEAD calls f(key).
+func (f SuiteFunc) NewAEAD(key []byte) (cipher.AEAD, error) {
+	return f(key)
+}
+
+var suites = map[Cipher]Suite{
+	CipherAESGCM: SuiteFunc(func(key []byte) (cipher.AEAD, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}),
+	CipherXChaCha20Poly1305: SuiteFunc(chacha20poly1305.NewX),
+}
+
+// RegisterSuite makes a cipher suite available under the name, which is
+// recorded in the envelopes it seals. It is meant to be called from init
+// functions and panics if the name is invalid or already registered.
+// Envelopes sealed with other suites than the built-in ones can only be
+// opened by clients registering the same suite.
+func RegisterSuite(c Cipher, s Suite) {
+	if c == "" || strings.ContainsAny(string(c), ";,= ") {
+		panic(fmt.Sprintf("crypto: invalid cipher name %q", c))
+	}
+	if s == nil {
+		panic("crypto: RegisterSuite suite is nil")
+	}
+	if _, ok := suites[c]; ok {
+		panic(fmt.Sprintf("crypto: RegisterSuite called twice for cipher %q", c))
+	}
+	suites[c] = s
+}
+
+// Ciphers returns the names of the registered cipher suites, sorted.
+func Ciphers() []string {
+	names := make([]string, 0, len(suites))
+	for c := range suites {
+		names = append(names, string(c))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCipher parses the name of a cipher suite, the empty string selects
+// the default CipherAESGCM.
+func ParseCipher(s string) (Cipher, error) {
+	if s == "" {
+		return CipherAESGCM, nil
+	}
+	if _, ok := suites[Cipher(s)]; !ok {
+		return "", fmt.Errorf("unsupported cipher %q. Use one of %s", s, strings.Join(Ciphers(), ", "))
+	}
+	return Cipher(s), nil
+}
+
+// newAEAD creates the AEAD of the cipher suite, the empty cipher selects
+// the default CipherAESGCM.
+func newAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	if c == "" {
+		c = CipherAESGCM
+	}
+	suite, ok := suites[c]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cipher %q", c)
+	}
+	return suite.NewAEAD(key)
+}
@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+const envelopeVectorsPath = "../../testdata/vectors/envelope.json"
+
+type envelopeVectors struct {
+	KDF []struct {
+		Name      string `json:"name"`
+		Key       string `json:"key"`
+		Field     string `json:"field"`
+		SecretKey string `json:"secret_key"`
+		FieldKey  string `json:"field_key"`
+	} `json:"kdf"`
+	Vectors []struct {
+		Name      string `json:"name"`
+		Key       string `json:"key"`
+		Nonce     string `json:"nonce"`
+		Field     string `json:"field"`
+		Plaintext string `json:"plaintext"`
+		Options   struct {
+			Cipher      Cipher      `json:"cipher"`
+			KDF         KDF         `json:"kdf"`
+			Compression Compression `json:"compression"`
+			Padding     Padding     `json:"padding"`
+			Manifest    bool        `json:"manifest"`
+		} `json:"options"`
+		DataURL  string `json:"data_url"`
+		OpenOnly bool   `json:"open_only"`
+	} `json:"vectors"`
+	Tamper []struct {
+		Name    string `json:"name"`
+		Key     string `json:"key"`
+		Field   string `json:"field"`
+		DataURL string `json:"data_url"`
+	} `json:"tamper"`
+}
+
+func loadEnvelopeVectors(tb testing.TB) envelopeVectors {
+	b, err := os.ReadFile(envelopeVectorsPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var v envelopeVectors
+	if err := json.Unmarshal(b, &v); err != nil {
+		tb.Fatal(err)
+	}
+	return v
+}
+
+func decodeKey(tb testing.TB, s string) []byte {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return key
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range loadEnvelopeVectors(t).Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			key := decodeKey(t, v.Key)
+
+			// Sealing with the nonce of the vector is deterministic
+			if !v.OpenOnly {
+				nonce, err := hex.DecodeString(v.Nonce)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts := Options{
+					Cipher:      v.Options.Cipher,
+					KDF:         v.Options.KDF,
+					Compression: v.Options.Compression,
+					Padding:     v.Options.Padding,
+					Manifest:    v.Options.Manifest,
+				}
+				nonceReader = bytes.NewReader(nonce)
+				dataURL, err := SealField([]byte(v.Plaintext), key, v.Field, opts)
+				nonceReader = rand.Reader
+				if err != nil {
+					t.Fatal(err)
+				}
+				if dataURL != v.DataURL {
+					t.Fatalf("got %s, want %s", dataURL, v.DataURL)
+				}
+			}
+
+			plaintext, err := OpenField(v.DataURL, key, v.Field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Wipe(plaintext)
+			if string(plaintext) != v.Plaintext {
+				t.Fatalf("got %q, want %q", plaintext, v.Plaintext)
+			}
+		})
+	}
+}
+
+func TestKDFVectors(t *testing.T) {
+	for _, v := range loadEnvelopeVectors(t).KDF {
+		t.Run(v.Name, func(t *testing.T) {
+			key := decodeKey(t, v.Key)
+
+			secretKey, err := SecretKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Destroy(secretKey)
+			if got := hex.EncodeToString(secretKey); got != v.SecretKey {
+				t.Fatalf("secret key %s, want %s", got, v.SecretKey)
+			}
+
+			fieldKey, err := FieldKey(key, v.Field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Destroy(fieldKey)
+			if got := hex.EncodeToString(fieldKey); got != v.FieldKey {
+				t.Fatalf("field key %s, want %s", got, v.FieldKey)
+			}
+		})
+	}
+}
+
+func TestTamperVectors(t *testing.T) {
+	for _, v := range loadEnvelopeVectors(t).Tamper {
+		t.Run(v.Name, func(t *testing.T) {
+			if _, err := OpenField(v.DataURL, decodeKey(t, v.Key), v.Field); err == nil {
+				t.Fatal("expected decryption to fail")
+			}
+		})
+	}
+}
+
+func FuzzDecryptStringFromDataURL(f *testing.F) {
+	vectors := loadEnvelopeVectors(f)
+	for _, v := range vectors.Vectors {
+		f.Add(v.DataURL, decodeKey(f, v.Key))
+	}
+	for _, v := range vectors.Tamper {
+		f.Add(v.DataURL, decodeKey(f, v.Key))
+	}
+
+	f.Fuzz(func(t *testing.T, dataURL string, key []byte) {
+		plaintext, err := DecryptStringFromDataURL(dataURL, key)
+		if err != nil {
+			return
+		}
+
+		// Whatever decrypts must survive a round trip with the same parameters
+		e, err := ParseEnvelope(dataURL)
+		if err != nil {
+			t.Fatalf("decrypted but envelope does not parse: %v", err)
+		}
+		sealed, err := Seal([]byte(plaintext), key, Options{Cipher: e.Params.Cipher, KDF: e.Params.KDF, Padding: e.Params.Padding})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecryptStringFromDataURL(sealed, key)
+		if err != nil || got != plaintext {
+			t.Fatalf("round trip failed: %v", err)
+		}
+	})
+}
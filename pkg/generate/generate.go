@@ -0,0 +1,248 @@
+package generate
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Mode selects the kind of value to generate.
+type Mode string
+
+const (
+	// ModePassword generates a random password from a character set.
+	ModePassword Mode = "password"
+	// ModeHex generates a random hexadecimal string.
+	ModeHex Mode = "hex"
+	// ModeBase64 generates random bytes encoded as unpadded URL-safe base64.
+	ModeBase64 Mode = "base64"
+	// ModeWords generates a passphrase from the EFF large wordlist.
+	ModeWords Mode = "words"
+)
+
+const (
+	// DefaultPasswordLength is the length of passwords if not specified otherwise.
+	DefaultPasswordLength = 24
+	// DefaultWords is the number of words of a passphrase if not specified otherwise.
+	DefaultWords = 6
+	// DefaultSeparator separates the words of a passphrase if not specified otherwise.
+	DefaultSeparator = "-"
+	// MaxLength limits the size of generated values.
+	MaxLength = 4096
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// wordlist is the EFF large wordlist, see
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+// licensed under CC BY 3.0 US.
+//
+//go:embed eff_large_wordlist.txt
+var wordlist string
+
+var words = strings.Fields(wordlist)
+
+// Spec describes the value to generate.
+type Spec struct {
+	Mode      Mode
+	Length    int
+	Symbols   bool
+	Separator string
+}
+
+// DefaultSpec returns the spec used if none is provided: a 24 characters
+// long alphanumeric password.
+func DefaultSpec() Spec {
+	return Spec{Mode: ModePassword, Length: DefaultPasswordLength}
+}
+
+// ParseSpec parses a comma separated spec such as "length=32,symbols",
+// "hex=40", "base64=32" or "words=6,separator=.". An empty spec results in
+// the DefaultSpec.
+func ParseSpec(s string) (Spec, error) {
+	spec := DefaultSpec()
+	if strings.TrimSpace(s) == "" {
+		return spec, nil
+	}
+
+	modeSet := false
+	setMode := func(m Mode, value string, def int) error {
+		if modeSet && spec.Mode != m {
+			return fmt.Errorf("conflicting generator modes %s and %s", spec.Mode, m)
+		}
+		modeSet = true
+		spec.Mode = m
+		spec.Length = def
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", m, value)
+		}
+		spec.Length = n
+		return nil
+	}
+
+	for _, opt := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		var err error
+		switch strings.ToLower(name) {
+		case "length", "len":
+			err = setMode(ModePassword, value, DefaultPasswordLength)
+		case "symbols":
+			spec.Symbols = true
+		case "hex":
+			err = setMode(ModeHex, value, 2*32)
+		case "base64":
+			err = setMode(ModeBase64, value, 32)
+		case "words", "passphrase":
+			err = setMode(ModeWords, value, DefaultWords)
+		case "separator", "sep":
+			spec.Separator = value
+		default:
+			err = fmt.Errorf("unknown generator option %q", name)
+		}
+		if err != nil {
+			return Spec{}, err
+		}
+	}
+
+	if spec.Symbols && spec.Mode != ModePassword {
+		return Spec{}, fmt.Errorf("symbols can only be used for passwords")
+	}
+	if spec.Separator != "" && spec.Mode != ModeWords {
+		return Spec{}, fmt.Errorf("separator can only be used for passphrases")
+	}
+	if spec.Mode == ModeWords && spec.Separator == "" {
+		spec.Separator = DefaultSeparator
+	}
+	if err := spec.validate(); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+// ParseFieldSpec parses a "field[:spec]" definition as used by the
+// --generate flag.
+func ParseFieldSpec(s string) (string, Spec, error) {
+	field, specString, _ := strings.Cut(s, ":")
+	if field == "" {
+		return "", Spec{}, fmt.Errorf("no field name in %q", s)
+	}
+	spec, err := ParseSpec(specString)
+	if err != nil {
+		return "", Spec{}, fmt.Errorf("invalid spec for %s: %v", field, err)
+	}
+	return field, spec, nil
+}
+
+func (s Spec) validate() error {
+	min := 1
+	if s.Mode == ModePassword {
+		// Leave room for one character of each class
+		min = 4
+	}
+	if s.Length < min || s.Length > MaxLength {
+		return fmt.Errorf("%s length must be between %d and %d", s.Mode, min, MaxLength)
+	}
+	return nil
+}
+
+// Generate generates a new random value according to the spec using crypto/rand.
+func Generate(spec Spec) (string, error) {
+	if err := spec.validate(); err != nil {
+		return "", err
+	}
+
+	switch spec.Mode {
+	case ModePassword:
+		return generatePassword(spec.Length, spec.Symbols)
+	case ModeHex:
+		b, err := randomBytes((spec.Length + 1) / 2)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b)[:spec.Length], nil
+	case ModeBase64:
+		b, err := randomBytes(spec.Length)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(b), nil
+	case ModeWords:
+		return generatePassphrase(spec.Length, spec.Separator)
+	default:
+		return "", fmt.Errorf("unknown generator mode %q", spec.Mode)
+	}
+}
+
+func generatePassword(length int, symbols bool) (string, error) {
+	classes := []string{lowerChars, upperChars, digitChars}
+	if symbols {
+		classes = append(classes, symbolChars)
+	}
+	charset := strings.Join(classes, "")
+
+	// Retry until each character class is contained at least once
+	for {
+		b := make([]byte, length)
+		for i := range b {
+			n, err := randomInt(len(charset))
+			if err != nil {
+				return "", err
+			}
+			b[i] = charset[n]
+		}
+
+		complete := true
+		for _, class := range classes {
+			if !strings.ContainsAny(string(b), class) {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return string(b), nil
+		}
+	}
+}
+
+func generatePassphrase(count int, separator string) (string, error) {
+	parts := make([]string, count)
+	for i := range parts {
+		n, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		parts[i] = words[n]
+	}
+	return strings.Join(parts, separator), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// randomInt returns a uniformly distributed random integer in [0, max).
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,207 @@
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Spec
+	}{
+		{"", Spec{Mode: ModePassword, Length: DefaultPasswordLength}},
+		{"length=32", Spec{Mode: ModePassword, Length: 32}},
+		{"len=8,symbols", Spec{Mode: ModePassword, Length: 8, Symbols: true}},
+		{"symbols", Spec{Mode: ModePassword, Length: DefaultPasswordLength, Symbols: true}},
+		{"hex", Spec{Mode: ModeHex, Length: 64}},
+		{"hex=40", Spec{Mode: ModeHex, Length: 40}},
+		{"base64=16", Spec{Mode: ModeBase64, Length: 16}},
+		{"words", Spec{Mode: ModeWords, Length: DefaultWords, Separator: DefaultSeparator}},
+		{"words=4,separator=.", Spec{Mode: ModeWords, Length: 4, Separator: "."}},
+		{" passphrase=5 , sep=_ ", Spec{Mode: ModeWords, Length: 5, Separator: "_"}},
+		{"length=4096", Spec{Mode: ModePassword, Length: MaxLength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSpec(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSpec(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	for _, in := range []string{
+		"length=abc",
+		"length=3",
+		"length=0",
+		"length=4097",
+		"hex=0",
+		"base64=-1",
+		"words=0",
+		"words=5000",
+		"hex,words",
+		"length=8,hex=8",
+		"hex,symbols",
+		"length=8,separator=.",
+		"unknown",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if spec, err := ParseSpec(in); err == nil {
+				t.Errorf("ParseSpec(%q) = %+v, want error", in, spec)
+			}
+		})
+	}
+}
+
+func TestParseFieldSpec(t *testing.T) {
+	field, spec, err := ParseFieldSpec("password:length=32,symbols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field != "password" || spec != (Spec{Mode: ModePassword, Length: 32, Symbols: true}) {
+		t.Errorf("got %s %+v", field, spec)
+	}
+	field, spec, err = ParseFieldSpec("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field != "token" || spec != DefaultSpec() {
+		t.Errorf("got %s %+v", field, spec)
+	}
+	for _, in := range []string{"", ":length=8", "password:hex=x"} {
+		if _, _, err := ParseFieldSpec(in); err == nil {
+			t.Errorf("ParseFieldSpec(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  Spec
+		check func(t *testing.T, v string)
+	}{
+		{"password", Spec{Mode: ModePassword, Length: 24}, func(t *testing.T, v string) {
+			assertLength(t, v, 24)
+			assertCharset(t, v, lowerChars+upperChars+digitChars)
+		}},
+		{"password symbols", Spec{Mode: ModePassword, Length: 40, Symbols: true}, func(t *testing.T, v string) {
+			assertLength(t, v, 40)
+			assertCharset(t, v, lowerChars+upperChars+digitChars+symbolChars)
+		}},
+		{"hex odd", Spec{Mode: ModeHex, Length: 41}, func(t *testing.T, v string) {
+			assertLength(t, v, 41)
+			if _, err := hex.DecodeString(v[:40]); err != nil {
+				t.Error(err)
+			}
+			assertCharset(t, v, "0123456789abcdef")
+		}},
+		{"base64", Spec{Mode: ModeBase64, Length: 32}, func(t *testing.T, v string) {
+			b, err := base64.RawURLEncoding.DecodeString(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b) != 32 {
+				t.Errorf("decoded %d bytes, want 32", len(b))
+			}
+		}},
+		{"words", Spec{Mode: ModeWords, Length: 6, Separator: "."}, func(t *testing.T, v string) {
+			parts := strings.Split(v, ".")
+			if len(parts) != 6 {
+				t.Fatalf("%d words, want 6", len(parts))
+			}
+			for _, p := range parts {
+				if !isWord(p) {
+					t.Errorf("%q is not in the wordlist", p)
+				}
+			}
+		}},
+		{"max length", Spec{Mode: ModePassword, Length: MaxLength}, func(t *testing.T, v string) {
+			assertLength(t, v, MaxLength)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Generate(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, v)
+		})
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	for _, spec := range []Spec{
+		{Mode: ModePassword, Length: 3},
+		{Mode: ModePassword, Length: MaxLength + 1},
+		{Mode: ModeHex, Length: 0},
+		{Mode: "unknown", Length: 8},
+	} {
+		if v, err := Generate(spec); err == nil {
+			t.Errorf("Generate(%+v) = %q, want error", spec, v)
+		}
+	}
+}
+
+// TestGenerateClassCoverage checks that the retry ensures every character
+// class appears, even at the minimum length where a single draw misses a
+// class most of the time.
+func TestGenerateClassCoverage(t *testing.T) {
+	for _, symbols := range []bool{false, true} {
+		classes := []string{lowerChars, upperChars, digitChars}
+		if symbols {
+			classes = append(classes, symbolChars)
+		}
+		for i := 0; i < 500; i++ {
+			v, err := Generate(Spec{Mode: ModePassword, Length: 4, Symbols: symbols})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, class := range classes {
+				if !strings.ContainsAny(v, class) {
+					t.Fatalf("%q contains no character of %q", v, class)
+				}
+			}
+		}
+	}
+}
+
+func TestWordlist(t *testing.T) {
+	if len(words) != 7776 {
+		t.Errorf("wordlist has %d words, want 7776", len(words))
+	}
+}
+
+func assertLength(t *testing.T, v string, n int) {
+	t.Helper()
+	if len(v) != n {
+		t.Errorf("length %d, want %d", len(v), n)
+	}
+}
+
+func assertCharset(t *testing.T, v, charset string) {
+	t.Helper()
+	for _, c := range v {
+		if !strings.ContainsRune(charset, c) {
+			t.Errorf("%q contains %q outside of the charset", v, c)
+		}
+	}
+}
+
+func isWord(w string) bool {
+	for _, word := range words {
+		if word == w {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package link
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SecretPathSegment is the path segment preceding the identifier in a secret link.
+const SecretPathSegment = "s"
+
+// KeySize is the size of a decoded link key in bytes.
+const KeySize = 32
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Link is a parsed secret link of the form BASE_URL/s/IDENTIFIER#KEY.
+type Link struct {
+	// BaseURL is the URL of the instance including an optional sub-path,
+	// without a trailing slash, e.g. https://example.secretify.io/team.
+	BaseURL string
+	// Identifier identifies the secret on the instance.
+	Identifier string
+	// Key is the decoded key from the fragment, nil if the link has none.
+	Key []byte
+}
+
+// Parse parses and validates a secret link. Query strings and trailing
+// slashes are ignored, the fragment may be URL-encoded and the instance may
+// be served from a sub-path.
+func Parse(raw string) (*Link, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid link: %v", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid link: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid link: no host")
+	}
+
+	// Find the last "/s/IDENTIFIER" in the path
+	segments := strings.Split(strings.TrimRight(u.Path, "/"), "/")
+	if len(segments) < 3 || segments[len(segments)-2] != SecretPathSegment {
+		return nil, fmt.Errorf("invalid link: path %q does not end with /%s/IDENTIFIER", u.Path, SecretPathSegment)
+	}
+	identifier := segments[len(segments)-1]
+	if err := ValidateIdentifier(identifier); err != nil {
+		return nil, err
+	}
+
+	l := &Link{
+		BaseURL:    u.Scheme + "://" + u.Host + strings.TrimRight(strings.Join(segments[:len(segments)-2], "/"), "/"),
+		Identifier: identifier,
+	}
+
+	// Decode the key from the fragment if present
+	if u.Fragment != "" {
+		key, err := DecodeKey(u.Fragment)
+		if err != nil {
+			return nil, err
+		}
+		l.Key = key
+	}
+	return l, nil
+}
+
+// String formats the link as BASE_URL/s/IDENTIFIER#KEY, omitting the
+// fragment if the link has no key.
+func (l *Link) String() string {
+	return Format(l.BaseURL, l.Identifier, l.Key)
+}
+
+// Origin returns the normalized scheme and host of the link.
+func (l *Link) Origin() string {
+	origin, err := Origin(l.BaseURL)
+	if err != nil {
+		return l.BaseURL
+	}
+	return origin
+}
+
+// Format formats a secret link for the given instance URL, identifier and key.
+// If key is nil, the link is formatted without fragment.
+func Format(baseURL, identifier string, key []byte) string {
+	s := fmt.Sprintf("%s/%s/%s", strings.TrimRight(baseURL, "/"), SecretPathSegment, url.PathEscape(identifier))
+	if key != nil {
+		s += "#" + EncodeKey(key)
+	}
+	return s
+}
+
+// EncodeKey encodes a key as used in the fragment of a link.
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes and validates a key from the fragment of a link. Padding
+// and surrounding whitespace are tolerated.
+func DecodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key: expected %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// ValidateIdentifier checks that the identifier only contains URL-safe characters.
+func ValidateIdentifier(identifier string) error {
+	if !identifierPattern.MatchString(identifier) {
+		return fmt.Errorf("invalid identifier %q", identifier)
+	}
+	return nil
+}
+
+// Origin returns the normalized origin (lower-case scheme and host, without
+// default port) of the given URL.
+func Origin(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid url %q", rawURL)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		host += ":" + port
+	}
+	return scheme + "://" + host, nil
+}
+
+// SameOrigin reports whether both URLs share the same origin.
+func SameOrigin(a, b string) bool {
+	originA, err := Origin(a)
+	if err != nil {
+		return false
+	}
+	originB, err := Origin(b)
+	if err != nil {
+		return false
+	}
+	return originA == originB
+}
@@ -0,0 +1,101 @@
+package link
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+const linkVectorsPath = "../../testdata/vectors/links.json"
+
+type linkVectors struct {
+	Valid []struct {
+		Name       string `json:"name"`
+		Link       string `json:"link"`
+		BaseURL    string `json:"base_url"`
+		Identifier string `json:"identifier"`
+		Key        string `json:"key"`
+		Canonical  string `json:"canonical"`
+	} `json:"valid"`
+	Invalid []struct {
+		Name string `json:"name"`
+		Link string `json:"link"`
+	} `json:"invalid"`
+}
+
+func loadLinkVectors(tb testing.TB) linkVectors {
+	b, err := os.ReadFile(linkVectorsPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var v linkVectors
+	if err := json.Unmarshal(b, &v); err != nil {
+		tb.Fatal(err)
+	}
+	return v
+}
+
+func TestParse(t *testing.T) {
+	for _, v := range loadLinkVectors(t).Valid {
+		t.Run(v.Name, func(t *testing.T) {
+			l, err := Parse(v.Link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.BaseURL != v.BaseURL {
+				t.Errorf("base url %q, want %q", l.BaseURL, v.BaseURL)
+			}
+			if l.Identifier != v.Identifier {
+				t.Errorf("identifier %q, want %q", l.Identifier, v.Identifier)
+			}
+			key, err := hex.DecodeString(v.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(l.Key, key) {
+				t.Errorf("key %x, want %s", l.Key, v.Key)
+			}
+			if l.String() != v.Canonical {
+				t.Errorf("formatted %q, want %q", l.String(), v.Canonical)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, v := range loadLinkVectors(t).Invalid {
+		t.Run(v.Name, func(t *testing.T) {
+			if _, err := Parse(v.Link); err == nil {
+				t.Fatal("expected parsing to fail")
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	vectors := loadLinkVectors(f)
+	for _, v := range vectors.Valid {
+		f.Add(v.Link)
+	}
+	for _, v := range vectors.Invalid {
+		f.Add(v.Link)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		l, err := Parse(raw)
+		if err != nil {
+			return
+		}
+
+		// Formatting a parsed link must result in an equal link
+		formatted, err := Parse(l.String())
+		if err != nil {
+			t.Fatalf("formatted link %q does not parse: %v", l.String(), err)
+		}
+		if formatted.BaseURL != l.BaseURL || formatted.Identifier != l.Identifier || !bytes.Equal(formatted.Key, l.Key) {
+			t.Fatalf("formatted link %q differs from %q", l.String(), raw)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the maximum number of shares, limited by the size of GF(256).
+const MaxShares = 255
+
+// expTable and logTable hold powers and logarithms of the generator 3 in
+// GF(256) with the reduction polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable = func() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		x = mulSlow(x, 3)
+	}
+	return exp, log
+}()
+
+// mulSlow multiplies in GF(256) without tables, used to build them.
+func mulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+// Split splits the secret into n shares of which any k reconstruct it. The
+// returned shares are indexed by their x coordinate 1..n.
+func Split(secret []byte, k, n int) (map[byte][]byte, error) {
+	if k < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if n < k {
+		return nil, errors.New("number of shares must not be less than the threshold")
+	}
+	if n > MaxShares {
+		return nil, fmt.Errorf("number of shares must not exceed %d", MaxShares)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+
+	shares := make(map[byte][]byte, n)
+	for x := 1; x <= n; x++ {
+		shares[byte(x)] = make([]byte, len(secret))
+	}
+
+	// Each byte of the secret is the constant term of a random polynomial of degree k-1
+	coefficients := make([]byte, k)
+	defer clear(coefficients)
+	for i, b := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		coefficients[0] = b
+
+		for x, share := range shares {
+			// Horner's method
+			var y byte
+			for j := k - 1; j >= 0; j-- {
+				y = mul(y, x) ^ coefficients[j]
+			}
+			share[i] = y
+		}
+	}
+	return shares, nil
+}
+
+// Combine reconstructs the secret from shares indexed by their x coordinate
+// using Lagrange interpolation at x=0. It cannot detect whether enough shares
+// were provided, callers have to ensure the threshold is met.
+func Combine(shares map[byte][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are required")
+	}
+
+	var length int
+	xs := make([]byte, 0, len(shares))
+	for x, share := range shares {
+		if x == 0 {
+			return nil, errors.New("invalid share index 0")
+		}
+		if length == 0 {
+			length = len(share)
+		}
+		if len(share) != length || length == 0 {
+			return nil, errors.New("shares differ in length")
+		}
+		xs = append(xs, x)
+	}
+
+	secret := make([]byte, length)
+	for _, xi := range xs {
+		// Lagrange basis polynomial of xi evaluated at 0
+		basis := byte(1)
+		for _, xj := range xs {
+			if xi != xj {
+				basis = mul(basis, div(xj, xj^xi))
+			}
+		}
+		for i, y := range shares[xi] {
+			secret[i] ^= mul(y, basis)
+		}
+	}
+	return secret, nil
+}
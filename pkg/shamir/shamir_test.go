@@ -0,0 +1,238 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Known-answer vector: the secret "ab" split with the polynomial
+// f(x) = s + 0x53·x, so that share x is s XOR 0x53·x in GF(256), i.e.
+// 0x53, 0xa6 and 0xf5 for x = 1, 2, 3.
+var (
+	katSecret = []byte("ab")
+	katShares = []string{
+		"ss1.0a1b2c3d.2of3.1.MjE.20456065",
+		"ss1.0a1b2c3d.2of3.2.x8Q.62b8f599",
+		"ss1.0a1b2c3d.2of3.3.lJc.e9ef9326",
+	}
+	katData = map[byte][]byte{
+		1: {0x32, 0x31},
+		2: {0xc7, 0xc4},
+		3: {0x94, 0x97},
+	}
+)
+
+func TestKnownAnswer(t *testing.T) {
+	var shares []Share
+	for _, str := range katShares {
+		s, err := ParseShare(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Group != "0a1b2c3d" || s.Threshold != 2 || s.Total != 3 || !bytes.Equal(s.Data, katData[s.Index]) {
+			t.Fatalf("ParseShare(%q) = %+v", str, s)
+		}
+		if s.String() != str {
+			t.Errorf("String() = %q, want %q", s.String(), str)
+		}
+		shares = append(shares, s)
+	}
+	for i := range shares {
+		for j := i + 1; j < len(shares); j++ {
+			secret, err := CombineShares([]Share{shares[i], shares[j]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(secret, katSecret) {
+				t.Errorf("shares %d and %d combine to %x, want %x", shares[i].Index, shares[j].Index, secret, katSecret)
+			}
+		}
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	// 0x53 and 0xca are inverses in the AES field
+	if got := mul(0x53, 0xca); got != 1 {
+		t.Errorf("mul(0x53, 0xca) = %#x, want 1", got)
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			p := mul(byte(a), byte(b))
+			if p != mulSlow(byte(a), byte(b)) {
+				t.Fatalf("mul(%#x, %#x) = %#x, want %#x", a, b, p, mulSlow(byte(a), byte(b)))
+			}
+			if div(p, byte(b)) != byte(a) {
+				t.Fatalf("div(%#x, %#x) = %#x, want %#x", p, b, div(p, byte(b)), a)
+			}
+		}
+	}
+}
+
+// TestRoundTrip combines every subset of at least K of the N shares.
+func TestRoundTrip(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	for n := 2; n <= 6; n++ {
+		for k := 2; k <= n; k++ {
+			t.Run(fmt.Sprintf("%d-of-%d", k, n), func(t *testing.T) {
+				shares, err := SplitShares(secret, k, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(shares) != n {
+					t.Fatalf("%d shares, want %d", len(shares), n)
+				}
+				for subset := 1; subset < 1<<n; subset++ {
+					var selected []Share
+					for i := 0; i < n; i++ {
+						if subset&(1<<i) != 0 {
+							// Round trip through the string encoding as well
+							s, err := ParseShare(shares[i].String())
+							if err != nil {
+								t.Fatal(err)
+							}
+							selected = append(selected, s)
+						}
+					}
+					got, err := CombineShares(selected)
+					if len(selected) < k {
+						if err == nil {
+							t.Errorf("subset %b: combined %d of %d shares", subset, len(selected), k)
+						}
+						continue
+					}
+					if err != nil {
+						t.Fatalf("subset %b: %v", subset, err)
+					}
+					if !bytes.Equal(got, secret) {
+						t.Errorf("subset %b: combined %x, want %x", subset, got, secret)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestBelowThreshold checks that Combine, which cannot detect a missing
+// share, does not reveal the secret from fewer than K shares.
+func TestBelowThreshold(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+	split, err := Split(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Combine(map[byte][]byte{1: split[1], 4: split[4]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Error("2 of 3 required shares reconstructed the secret")
+	}
+}
+
+func TestCombineSharesInvalid(t *testing.T) {
+	secret := []byte("secret")
+	a, err := SplitShares(secret, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := SplitShares(secret, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := SplitShares(secret, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		shares []Share
+		want   string
+	}{
+		{"none", nil, "no shares"},
+		{"below threshold", a[:1], "1 of 2 required"},
+		{"different splits", []Share{a[0], b[1]}, "same secret"},
+		{"different threshold", []Share{a[0], c[1]}, "same secret"},
+		{"duplicate index", []Share{a[0], a[0]}, "more than once"},
+		{"duplicate index different data", []Share{a[1], {Group: a[1].Group, Threshold: 2, Total: 3, Index: 2, Data: a[2].Data}}, "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CombineShares(tt.shares)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	for name, shares := range map[string]map[byte][]byte{
+		"one share":        {1: {1, 2}},
+		"index 0":          {0: {1, 2}, 1: {3, 4}},
+		"different length": {1: {1, 2}, 2: {3}},
+		"empty":            {1: {}, 2: {}},
+	} {
+		if _, err := Combine(shares); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		secret []byte
+		k, n   int
+	}{
+		{[]byte("s"), 1, 3},
+		{[]byte("s"), 3, 2},
+		{[]byte("s"), 2, MaxShares + 1},
+		{nil, 2, 3},
+	} {
+		if _, err := Split(tt.secret, tt.k, tt.n); err == nil {
+			t.Errorf("Split(%q, %d, %d) succeeded, want error", tt.secret, tt.k, tt.n)
+		}
+	}
+}
+
+func TestParseShareInvalid(t *testing.T) {
+	valid := katShares[0]
+	tests := map[string]string{
+		"empty":             "",
+		"prefix":            "ss2" + valid[3:],
+		"missing part":      "ss1.0a1b2c3d.2of3.1.MjE",
+		"tampered checksum": valid[:len(valid)-1] + "6",
+		"tampered data":     strings.Replace(valid, ".MjE.", ".MjF.", 1),
+		"tampered group":    strings.Replace(valid, "0a1b2c3d", "0a1b2c3e", 1),
+		"tampered length":   strings.Replace(valid, ".MjE.", ".Mj.", 1),
+		"truncated data":    strings.Replace(valid, ".MjE.", ".M.", 1),
+		"tampered scheme":   strings.Replace(valid, "2of3", "3of3", 1),
+		"scheme":            strings.Replace(valid, "2of3", "2-3", 1),
+		"index 0":           strings.Replace(valid, ".1.", ".0.", 1),
+		"index 256":         strings.Replace(valid, ".1.", ".256.", 1),
+	}
+	for name, str := range tests {
+		t.Run(name, func(t *testing.T) {
+			if s, err := ParseShare(str); err == nil {
+				t.Errorf("ParseShare(%q) = %+v, want error", str, s)
+			}
+		})
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	k, n, err := ParseScheme(" 3-of-5 ")
+	if err != nil || k != 3 || n != 5 {
+		t.Errorf("ParseScheme = %d, %d, %v", k, n, err)
+	}
+	for _, s := range []string{"", "3of5", "x-of-5", "3-of-x", "1-of-3", "4-of-3", "2-of-256"} {
+		if _, _, err := ParseScheme(s); err == nil {
+			t.Errorf("ParseScheme(%q) succeeded, want error", s)
+		}
+	}
+}
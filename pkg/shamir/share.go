@@ -0,0 +1,155 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SharePrefix identifies the share format and its version.
+const SharePrefix = "ss1"
+
+// checksumSize is the number of bytes of the SHA-256 checksum appended to a share.
+const checksumSize = 4
+
+// Share is a single share of a split secret. Its string representation is
+//
+//	ss1.GROUP.KofN.INDEX.DATA.CHECKSUM
+//
+// where GROUP identifies the shares of one split, K is the threshold, N the
+// number of shares, DATA the base64url encoded share and CHECKSUM the first
+// 4 bytes of the SHA-256 over everything preceding it, hex encoded.
+type Share struct {
+	Group     string
+	Threshold int
+	Total     int
+	Index     byte
+	Data      []byte
+}
+
+// ParseScheme parses a "K-of-N" scheme such as "3-of-5".
+func ParseScheme(s string) (int, int, error) {
+	kString, nString, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "-of-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid split %q. Use e.g. 3-of-5", s)
+	}
+	k, err := strconv.Atoi(kString)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid threshold %q", kString)
+	}
+	n, err := strconv.Atoi(nString)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid number of shares %q", nString)
+	}
+	if k < 2 || n < k || n > MaxShares {
+		return 0, 0, fmt.Errorf("invalid split %q: requires 2 <= K <= N <= %d", s, MaxShares)
+	}
+	return k, n, nil
+}
+
+// SplitShares splits the secret into n shares of which any k reconstruct it.
+func SplitShares(secret []byte, k, n int) ([]Share, error) {
+	split, err := Split(secret, k, n)
+	if err != nil {
+		return nil, err
+	}
+
+	groupBytes := make([]byte, 4)
+	if _, err := rand.Read(groupBytes); err != nil {
+		return nil, err
+	}
+	group := hex.EncodeToString(groupBytes)
+
+	shares := make([]Share, 0, n)
+	for x := 1; x <= n; x++ {
+		shares = append(shares, Share{
+			Group:     group,
+			Threshold: k,
+			Total:     n,
+			Index:     byte(x),
+			Data:      split[byte(x)],
+		})
+	}
+	return shares, nil
+}
+
+// CombineShares validates that the shares belong together and meet the
+// threshold and reconstructs the secret.
+func CombineShares(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares provided")
+	}
+
+	first := shares[0]
+	m := make(map[byte][]byte, len(shares))
+	for _, s := range shares {
+		if s.Group != first.Group || s.Threshold != first.Threshold || s.Total != first.Total {
+			return nil, errors.New("shares do not belong to the same secret")
+		}
+		if _, ok := m[s.Index]; ok {
+			return nil, fmt.Errorf("share %d provided more than once", s.Index)
+		}
+		m[s.Index] = s.Data
+	}
+	if len(m) < first.Threshold {
+		return nil, fmt.Errorf("%d of %d required shares provided", len(m), first.Threshold)
+	}
+	return Combine(m)
+}
+
+func (s Share) header() string {
+	return fmt.Sprintf("%s.%s.%dof%d.%d.%s", SharePrefix, s.Group, s.Threshold, s.Total, s.Index, base64.RawURLEncoding.EncodeToString(s.Data))
+}
+
+func checksum(header string) string {
+	sum := sha256.Sum256([]byte(header))
+	return hex.EncodeToString(sum[:checksumSize])
+}
+
+// String encodes the share including its checksum.
+func (s Share) String() string {
+	h := s.header()
+	return h + "." + checksum(h)
+}
+
+// ParseShare decodes a share and verifies its checksum.
+func ParseShare(str string) (Share, error) {
+	parts := strings.Split(strings.TrimSpace(str), ".")
+	if len(parts) != 6 || parts[0] != SharePrefix {
+		return Share{}, errors.New("invalid share format")
+	}
+
+	var s Share
+	s.Group = parts[1]
+
+	k, n, ok := strings.Cut(parts[2], "of")
+	if !ok {
+		return Share{}, errors.New("invalid share threshold")
+	}
+	var err error
+	if s.Threshold, err = strconv.Atoi(k); err != nil {
+		return Share{}, errors.New("invalid share threshold")
+	}
+	if s.Total, err = strconv.Atoi(n); err != nil {
+		return Share{}, errors.New("invalid share threshold")
+	}
+	index, err := strconv.Atoi(parts[3])
+	if err != nil || index < 1 || index > MaxShares {
+		return Share{}, errors.New("invalid share index")
+	}
+	s.Index = byte(index)
+	if s.Data, err = base64.RawURLEncoding.Strict().DecodeString(parts[4]); err != nil {
+		return Share{}, fmt.Errorf("invalid share data: %v", err)
+	}
+
+	// The checksum covers the share as typed, not its decoded form
+	if checksum(strings.Join(parts[:5], ".")) != parts[5] {
+		return Share{}, errors.New("invalid share checksum, the share may be mistyped or truncated")
+	}
+	return s, nil
+}